@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Aggregate returns the minimal list of CIDRs covering the CIDRs, ranges and IP addresses,
+// with the IPv4 CIDRs first.
+func Aggregate(entries []string) ([]string, error) {
+	ranges, err := ParseIPRanges(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, r := range mergeRanges(ranges) {
+		res = append(res, r.CIDRs()...)
+	}
+
+	return res, nil
+}
+
+func PrintAggregate(args []string) int {
+	if err := checkStdin(args); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	var entries []string
+	for _, list := range args {
+		list, err := ReadList(list)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		entries = append(entries, list...)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No CIDR, range or IP address supplied.")
+		return 1
+	}
+
+	res, err := Aggregate(entries)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	for _, element := range res {
+		fmt.Println(element)
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		entries []string
+		want    []string
+		wantErr bool
+	}{
+		{[]string{"10.0.0.0/25", "10.0.0.128/25"}, []string{"10.0.0.0/24"}, false},
+		{[]string{"10.0.0.0/24", "10.0.0.64/26", "10.0.0.7"}, []string{"10.0.0.0/24"}, false},
+		{[]string{"10.0.1.0/24", "10.0.0.0/24", "10.0.3.0/24"}, []string{"10.0.0.0/23", "10.0.3.0/24"}, false},
+		{[]string{"10.0.0.1-10.0.0.6", "10.0.0.0", "10.0.0.7"}, []string{"10.0.0.0/29"}, false},
+		{[]string{"2001:db8::/33", "10.0.0.0/8", "2001:db8:8000::/33"}, []string{"10.0.0.0/8", "2001:db8::/32"}, false},
+		{[]string{"0.0.0.0/1", "128.0.0.0/1", "255.255.255.255"}, []string{"0.0.0.0/0"}, false},
+		{[]string{"::/1", "8000::/1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}, []string{"::/0"}, false},
+		{[]string{"1.2.3.4", "1.2.3.5", "1.2.3.6"}, []string{"1.2.3.4/31", "1.2.3.6/32"}, false},
+		{[]string{"10.0.0.0/8", "x"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Aggregate(tt.entries)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Aggregate(%v) = %v, %v, want %v", tt.entries, got, err, tt.want)
+		}
+	}
+}
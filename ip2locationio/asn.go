@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ParseASN returns the AS number written with or without the AS prefix.
+func ParseASN(str string) (uint32, error) {
+	asn, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(str)), "AS"), 10, 32)
+	if err != nil {
+		return 0, errors.New("Not a valid ASN: " + str)
+	}
+	return uint32(asn), nil
+}
+
+// ASNPrefixes returns the sorted prefixes originated by the ASN in the routing table, where each line is
+// either "PREFIX ORIGIN", "PREFIX AS PATH", "ADDRESS LENGTH ORIGIN" as in the CAIDA prefix-to-AS files,
+// or a "|" separated route written by bgpdump -m. Multiple origins are separated by "_" or ",".
+func ASNPrefixes(r io.Reader, asn uint32) ([]netip.Prefix, error) {
+	seen := make(map[netip.Prefix]bool)
+	var res []netip.Prefix
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, origins, ok := parseRoute(line)
+		if !ok || seen[p] || !containsASN(origins, asn) {
+			continue
+		}
+		seen[p] = true
+		res = append(res, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Addr().Is6() != res[j].Addr().Is6() {
+			return !res[i].Addr().Is6()
+		}
+		if c := res[i].Addr().Compare(res[j].Addr()); c != 0 {
+			return c < 0
+		}
+		return res[i].Bits() < res[j].Bits()
+	})
+
+	return res, nil
+}
+
+// returns the prefix and the origin ASNs of the route.
+func parseRoute(line string) (netip.Prefix, string, bool) {
+	var prefix, origins string
+
+	if fields := strings.Split(line, "|"); len(fields) > 6 {
+		path := strings.Fields(fields[6])
+		if len(path) == 0 {
+			return netip.Prefix{}, "", false
+		}
+		prefix, origins = fields[5], path[len(path)-1]
+	} else if fields := strings.Fields(line); len(fields) >= 2 && strings.Contains(fields[0], "/") {
+		prefix, origins = fields[0], fields[len(fields)-1]
+	} else if len(fields) == 3 {
+		prefix, origins = fields[0]+"/"+fields[1], fields[2]
+	} else {
+		return netip.Prefix{}, "", false
+	}
+
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return netip.Prefix{}, "", false
+	}
+	return p.Masked(), origins, true
+}
+
+// returns true if the ASN is one of the origins, which may be an AS set such as {1,2}.
+func containsASN(origins string, asn uint32) bool {
+	origins = strings.Trim(origins, "{}")
+	for _, s := range strings.FieldsFunc(origins, func(r rune) bool { return r == '_' || r == ',' }) {
+		if n, err := strconv.ParseUint(s, 10, 32); err == nil && uint32(n) == asn {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the prefixes of the ASN in the routing table file, which may be gzip compressed.
+func readASNPrefixes(path string, asn uint32) ([]netip.Prefix, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return ASNPrefixes(r, asn)
+}
+
+// returns the address to geolocate for the prefix, which is the first usable host.
+func sampleAddr(p netip.Prefix) netip.Addr {
+	if p.Bits() < p.Addr().BitLen()-1 {
+		return p.Addr().Next()
+	}
+	return p.Addr()
+}
+
+// returns the string field of the lookup result, or "-" if there is none.
+func stringField(ipl map[string]interface{}, key string) string {
+	if s, ok := ipl[key].(string); ok && s != "" {
+		return s
+	}
+	return "-"
+}
+
+func PrintASN(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("No ASN supplied.")
+		return 1
+	}
+
+	asn, err := ParseASN(args[0])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	} else if asnRoutes == "" {
+		fmt.Println("No routing table supplied. Use --routes FILE.")
+		return 1
+	}
+
+	prefixes, err := readASNPrefixes(asnRoutes, asn)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	} else if len(prefixes) == 0 {
+		fmt.Printf("No prefix found for AS%d.\n", asn)
+		return 1
+	}
+
+	if !asnGeolocate {
+		for _, p := range prefixes {
+			fmt.Println(p)
+		}
+		return 0
+	}
+
+	var w *tabwriter.Writer
+	if outputFormat != "json" {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PREFIX\tIP\tCOUNTRY\tREGION\tCITY")
+		defer w.Flush()
+	}
+
+	for _, p := range prefixes {
+		ip := sampleAddr(p).String()
+
+		if w == nil {
+			res, err := lookUpResult(ip)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", ip, err)
+				continue
+			}
+
+			// the prefix is added to the lookup result fields
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(res), &fields); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", ip, err)
+				continue
+			}
+			fields["prefix"], _ = json.Marshal(p.String())
+
+			str, _ := json.Marshal(fields)
+			fmt.Println(string(str))
+			continue
+		}
+
+		ipl, err := lookUpResultMap(ip)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", ip, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p, ip, stringField(ipl, "country_code"), stringField(ipl, "region_name"), stringField(ipl, "city_name"))
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestASNPrefixes(t *testing.T) {
+	table := `# comment
+10.0.0.0/8 64500
+10.1.0.0/16 64501 64500
+10.1.0.0/16 64502 64500
+192.0.2.0	24	64500_64501
+198.51.100.0	24	64501
+2001:db8::/32 {64500,64511}
+2001:db8::/32 64500
+TABLE_DUMP2|1700000000|B|192.0.2.1|64510|203.0.113.0/24|64510 64500|IGP|192.0.2.1|0|0||NAG||
+TABLE_DUMP2|1700000000|B|192.0.2.1|64510|203.0.112.0/24|64500 64510|IGP|192.0.2.1|0|0||NAG||
+10.0.0.1/8 645000
+invalid/33 64500
+`
+
+	got, err := ASNPrefixes(strings.NewReader(table), 64500)
+	want := []string{"10.0.0.0/8", "10.1.0.0/16", "192.0.2.0/24", "203.0.113.0/24", "2001:db8::/32"}
+
+	var gotStr []string
+	for _, p := range got {
+		gotStr = append(gotStr, p.String())
+	}
+	if err != nil || !reflect.DeepEqual(gotStr, want) {
+		t.Errorf("ASNPrefixes = %v, %v, want %v", gotStr, err, want)
+	}
+}
+
+func TestParseASN(t *testing.T) {
+	tests := map[string]uint32{"15169": 15169, "AS15169": 15169, "as4200000000": 4200000000, " AS1 ": 1}
+	for str, want := range tests {
+		if got, err := ParseASN(str); err != nil || got != want {
+			t.Errorf("ParseASN(%q) = %d, %v, want %d", str, got, err, want)
+		}
+	}
+
+	for _, str := range []string{"", "AS", "ASX", "4294967296", "-1"} {
+		if _, err := ParseASN(str); err == nil {
+			t.Errorf("ParseASN(%q) error = nil, want error", str)
+		}
+	}
+}
+
+func TestSampleAddr(t *testing.T) {
+	tests := map[string]string{
+		"8.8.8.0/24":     "8.8.8.1",
+		"10.0.0.0/31":    "10.0.0.0",
+		"10.0.0.5/32":    "10.0.0.5",
+		"2001:db8::/32":  "2001:db8::1",
+		"2001:db8::/128": "2001:db8::",
+	}
+
+	for prefix, want := range tests {
+		if got := sampleAddr(netip.MustParsePrefix(prefix)).String(); got != want {
+			t.Errorf("sampleAddr(%s) = %s, want %s", prefix, got, want)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/netip"
+	"os"
+	"strconv"
+	"time"
+)
+
+// The BinDB struct reads the IP2Location BIN database file for offline lookups.
+type BinDB struct {
+	f         *os.File
+	dbType    uint8
+	colCount  uint8
+	year      uint8
+	month     uint8
+	day       uint8
+	ipv4Count uint32
+	ipv4Base  uint32
+	ipv6Count uint32
+	ipv6Base  uint32
+	ipv4Index uint32
+	ipv6Index uint32
+}
+
+// The binMissError type is the error of an IP address which is not in the database.
+type binMissError string
+
+func (e binMissError) Error() string {
+	return string(e)
+}
+
+// the kinds of BIN columns
+const (
+	binString = iota
+	binCountryCode
+	binCountryName
+	binFloat
+	binNumber
+)
+
+// The binColumn struct stores a result field and its column in each of the database types DB1 to DB26,
+// where 0 means the database type does not have it and 1 is the IP address column.
+type binColumn struct {
+	Key       string
+	Kind      int
+	Positions [27]uint8
+}
+
+// the BIN columns in the order of the API result fields
+var binColumns = []binColumn{
+	{"country_code", binCountryCode, [27]uint8{0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}},
+	{"country_name", binCountryName, [27]uint8{0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}},
+	{"region_name", binString, [27]uint8{0, 0, 0, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3}},
+	{"city_name", binString, [27]uint8{0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4}},
+	{"latitude", binFloat, [27]uint8{0, 0, 0, 0, 0, 5, 5, 0, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5}},
+	{"longitude", binFloat, [27]uint8{0, 0, 0, 0, 0, 6, 6, 0, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6}},
+	{"zip_code", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 7, 7, 7, 7, 0, 7, 7, 7, 0, 7, 0, 7, 7, 7, 0, 7, 7, 7}},
+	{"time_zone", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 8, 7, 8, 8, 8, 7, 8, 0, 8, 8, 8, 0, 8, 8, 8}},
+	{"asn", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 24}},
+	{"as", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 25}},
+	{"isp", binString, [27]uint8{0, 0, 3, 0, 5, 0, 7, 5, 7, 0, 8, 0, 9, 0, 9, 0, 9, 0, 9, 7, 9, 0, 9, 7, 9, 9, 9}},
+	{"domain", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 6, 8, 0, 9, 0, 10, 0, 10, 0, 10, 0, 10, 8, 10, 0, 10, 8, 10, 10, 10}},
+	{"net_speed", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 11, 0, 11, 8, 11, 0, 11, 0, 11, 0, 11, 11, 11}},
+	{"idd_code", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 12, 0, 12, 0, 12, 9, 12, 0, 12, 12, 12}},
+	{"area_code", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 13, 0, 13, 0, 13, 10, 13, 0, 13, 13, 13}},
+	{"weather_station_code", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 14, 0, 14, 0, 14, 0, 14, 14, 14}},
+	{"weather_station_name", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 15, 0, 15, 0, 15, 0, 15, 15, 15}},
+	{"mcc", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 16, 0, 16, 9, 16, 16, 16}},
+	{"mnc", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 17, 0, 17, 10, 17, 17, 17}},
+	{"mobile_brand", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 11, 18, 0, 18, 11, 18, 18, 18}},
+	{"elevation", binNumber, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 11, 19, 0, 19, 19, 19}},
+	{"usage_type", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 12, 20, 20, 20}},
+	{"address_type", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 21, 21}},
+	{"district", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 23}},
+	{"ads_category", binString, [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 22, 22}},
+}
+
+// OpenBinDB opens the IP2Location BIN database file and reads its header.
+func OpenBinDB(path string) (*BinDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var h [32]byte
+	if _, err := f.ReadAt(h[:], 0); err != nil {
+		f.Close()
+		return nil, errors.New("Not a valid IP2Location BIN file: " + path)
+	}
+
+	le := binary.LittleEndian
+	db := &BinDB{
+		f:         f,
+		dbType:    h[0],
+		colCount:  h[1],
+		year:      h[2],
+		month:     h[3],
+		day:       h[4],
+		ipv4Count: le.Uint32(h[5:]),
+		ipv4Base:  le.Uint32(h[9:]),
+		ipv6Count: le.Uint32(h[13:]),
+		ipv6Base:  le.Uint32(h[17:]),
+		ipv4Index: le.Uint32(h[21:]),
+		ipv6Index: le.Uint32(h[25:]),
+	}
+
+	// the product code is 1 for IP2Location since the 2021 databases, other products use the same format
+	if db.dbType == 0 || db.dbType > 26 || db.colCount < 2 || db.ipv4Base == 0 || (db.year >= 21 && h[29] != 1) {
+		f.Close()
+		return nil, errors.New("Not a valid IP2Location BIN file: " + path)
+	}
+
+	return db, nil
+}
+
+// Close closes the database file.
+func (db *BinDB) Close() error {
+	return db.f.Close()
+}
+
+// Date returns the release date of the database.
+func (db *BinDB) Date() time.Time {
+	return time.Date(2000+int(db.year), time.Month(db.month), int(db.day), 0, 0, 0, 0, time.UTC)
+}
+
+// LookUp returns the fields of the database type for the IP address in the same JSON shape as the API.
+func (db *BinDB) LookUp(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Zone() != "" {
+		return "", errors.New("Not a valid IP address.")
+	}
+	addr = addr.Unmap()
+
+	// the 6to4 and Teredo addresses are looked up with their IPv4 address as ip2location-go does
+	if e, err := DecodeIPv6(addr.String()); err == nil && (e.Mechanism == "6to4" || e.Mechanism == "teredo") {
+		addr = netip.MustParseAddr(e.IPv4)
+	}
+
+	row, err := db.findRow(uint128FromAddr(addr), addr.Is6())
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	value, _ := json.Marshal(ip)
+	buf.WriteString(`{"ip":`)
+	buf.Write(value)
+
+	for _, c := range binColumns {
+		pos := c.Positions[db.dbType]
+		if pos == 0 {
+			continue
+		}
+
+		// the columns after the IP address are 4 bytes each
+		off := (int(pos) - 2) * 4
+		if off+4 > len(row) {
+			return "", errors.New("Not a valid IP2Location BIN file.")
+		}
+		field := binary.LittleEndian.Uint32(row[off:])
+
+		value, err := db.columnValue(c.Kind, field)
+		if err != nil {
+			return "", err
+		}
+
+		key, _ := json.Marshal(c.Key)
+		buf.WriteByte(',')
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+	return buf.String(), nil
+}
+
+// returns the JSON value of the column, where the field is the pointer to the string or the float bits.
+func (db *BinDB) columnValue(kind int, field uint32) ([]byte, error) {
+	switch kind {
+	case binFloat:
+		// the coordinates are float32, so use the shortest decimal which reads back as the same float32
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(math.Float32frombits(field)), 'f', -1, 32), 64)
+		return json.Marshal(f)
+	case binCountryName:
+		// the country name follows the 2-letter code and its length byte
+		field += 3
+	}
+
+	str, err := db.readString(field)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == binNumber {
+		n, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			n = 0
+		}
+		return json.Marshal(n)
+	}
+	return json.Marshal(str)
+}
+
+// returns the columns after the IP address of the row whose range contains the IP number.
+func (db *BinDB) findRow(ipNum uint128, ipv6 bool) ([]byte, error) {
+	count, base, index := db.ipv4Count, db.ipv4Base, db.ipv4Index
+	ipSize := 4
+	maxIP := lowMask(32)
+	if ipv6 {
+		count, base, index = db.ipv6Count, db.ipv6Base, db.ipv6Index
+		ipSize = 16
+		maxIP = lowMask(128)
+	}
+
+	if count == 0 && ipv6 {
+		return nil, binMissError("The database has no IPv6 data.")
+	} else if count == 0 {
+		return nil, binMissError("The database has no IPv4 data.")
+	}
+
+	// the last address belongs to the last range
+	if ipNum == maxIP {
+		ipNum = ipNum.Sub(uint128{0, 1})
+	}
+
+	low, high := int64(0), int64(count)
+
+	// the index has the first and last rows for each value of the top 16 bits
+	if index > 0 {
+		key := ipNum.Lo >> 16
+		if ipv6 {
+			key = ipNum.Hi >> 48
+		}
+		var b [8]byte
+		if err := db.readAt(b[:], int64(index)+int64(key)<<3); err != nil {
+			return nil, err
+		}
+		low, high = int64(binary.LittleEndian.Uint32(b[:])), int64(binary.LittleEndian.Uint32(b[4:]))
+	}
+
+	rowSize := ipSize + (int(db.colCount)-1)*4
+	// each row is read with the start of the next row, which is the end of its range
+	row := make([]byte, rowSize+ipSize)
+
+	for low <= high {
+		mid := (low + high) / 2
+		if err := db.readAt(row, int64(base)+mid*int64(rowSize)); err != nil {
+			return nil, err
+		}
+
+		from := binIPNumber(row[:ipSize])
+		to := binIPNumber(row[rowSize:])
+
+		if ipNum.Cmp(from) < 0 {
+			high = mid - 1
+		} else if ipNum.Cmp(to) >= 0 {
+			low = mid + 1
+		} else {
+			return row[ipSize:rowSize], nil
+		}
+	}
+
+	return nil, binMissError("IP address not found in the database.")
+}
+
+// reads at the 1-based offset used by the header and the rows.
+func (db *BinDB) readAt(b []byte, pos int64) error {
+	if _, err := db.f.ReadAt(b, pos-1); err != nil {
+		return errors.New("Not a valid IP2Location BIN file.")
+	}
+	return nil
+}
+
+// reads the string with the length byte at the 0-based offset.
+func (db *BinDB) readString(pos uint32) (string, error) {
+	var n [1]byte
+	if _, err := db.f.ReadAt(n[:], int64(pos)); err != nil {
+		return "", errors.New("Not a valid IP2Location BIN file.")
+	}
+
+	b := make([]byte, n[0])
+	if _, err := db.f.ReadAt(b, int64(pos)+1); err != nil {
+		return "", errors.New("Not a valid IP2Location BIN file.")
+	}
+	return string(b), nil
+}
+
+// returns the little-endian IPv4 or IPv6 number.
+func binIPNumber(b []byte) uint128 {
+	if len(b) == 4 {
+		return uint128{0, uint64(binary.LittleEndian.Uint32(b))}
+	}
+	return uint128{binary.LittleEndian.Uint64(b[8:]), binary.LittleEndian.Uint64(b[:8])}
+}
+
+// the database opened for --db
+var lookupBinDB *BinDB
+var lookupBinDBPath string
+
+// returns the database for --db, opening it on first use.
+func openLookupDB() (*BinDB, error) {
+	if lookupBinDB != nil && lookupBinDBPath == lookupDB {
+		return lookupBinDB, nil
+	}
+	if lookupBinDB != nil {
+		lookupBinDB.Close()
+		lookupBinDB = nil
+	}
+
+	db, err := OpenBinDB(lookupDB)
+	if err != nil {
+		return nil, err
+	}
+	lookupBinDB, lookupBinDBPath = db, lookupDB
+	return db, nil
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// The binTestRow struct stores the start of a range and its fields for buildBinDB.
+type binTestRow struct {
+	From   string
+	Values map[string]string
+}
+
+var google = map[string]string{
+	"country_code": "US", "country_name": "United States of America", "region_name": "California", "city_name": "Mountain View",
+	"latitude": "37.405992", "longitude": "-122.078515", "zip_code": "94043", "time_zone": "-07:00", "asn": "15169", "as": "Google LLC",
+	"isp": "Google LLC", "domain": "google.com", "net_speed": "T1", "idd_code": "1", "area_code": "650",
+	"weather_station_code": "USCA0746", "weather_station_name": "Mountain View", "mcc": "-", "mnc": "-", "mobile_brand": "-",
+	"elevation": "32", "usage_type": "DCH", "address_type": "Anycast", "district": "Santa Clara County", "ads_category": "IAB19-11",
+}
+
+var cloudflare = map[string]string{
+	"country_code": "AU", "country_name": "Australia", "region_name": "Queensland", "city_name": "Brisbane",
+	"latitude": "-27.46754", "longitude": "153.02809", "zip_code": "4000", "time_zone": "+10:00", "asn": "13335", "as": "CloudFlare Inc",
+	"isp": "APNIC and CloudFlare DNS Resolver Project", "domain": "cloudflare.com", "net_speed": "T1", "idd_code": "61", "area_code": "07",
+	"weather_station_code": "ASXX0016", "weather_station_name": "Brisbane", "mcc": "-", "mnc": "-", "mobile_brand": "-",
+	"elevation": "28", "usage_type": "DCH/SES", "address_type": "Anycast", "district": "Brisbane", "ads_category": "IAB19-11",
+}
+
+var binTestRows4 = []binTestRow{
+	{"0.0.0.0", nil},
+	{"1.1.1.0", cloudflare},
+	{"1.1.2.0", nil},
+	{"8.8.8.0", google},
+	{"8.8.9.0", nil},
+}
+
+var binTestRows6 = []binTestRow{
+	{"::", nil},
+	{"2001:4860::", google},
+	{"2001:4861::", nil},
+}
+
+// returns the IP2Location BIN database of the type with the rows, where the fields not in a row are "-".
+func buildBinDB(dbType uint8, rows4 []binTestRow, rows6 []binTestRow, index bool) []byte {
+	colCount := 2
+	for _, c := range binColumns {
+		if int(c.Positions[dbType]) > colCount {
+			colCount = int(c.Positions[dbType])
+		}
+	}
+	rowSize4 := colCount * 4
+	rowSize6 := 16 + (colCount-1)*4
+
+	// the ranges are followed by the end of the last one
+	ipv4Base := 65
+	ipv6Base := ipv4Base + (len(rows4)+1)*rowSize4
+	size := ipv6Base + (len(rows6)+1)*rowSize6 - 1
+	ipv4Index, ipv6Index := 0, 0
+	if index {
+		ipv4Index, ipv6Index = size+1, size+1+65536*8
+		size += 2 * 65536 * 8
+	}
+
+	le := binary.LittleEndian
+	out := make([]byte, size)
+	out[0], out[1], out[2], out[3], out[4] = dbType, byte(colCount), 24, 1, 1
+	le.PutUint32(out[5:], uint32(len(rows4)))
+	le.PutUint32(out[9:], uint32(ipv4Base))
+	le.PutUint32(out[13:], uint32(len(rows6)))
+	le.PutUint32(out[17:], uint32(ipv6Base))
+	le.PutUint32(out[21:], uint32(ipv4Index))
+	le.PutUint32(out[25:], uint32(ipv6Index))
+	out[29] = 1
+
+	// the strings are stored once after the rows, with the country name 3 bytes after the code
+	pointers := make(map[string]uint32)
+	addString := func(str string, pad int) uint32 {
+		if p, ok := pointers[str]; ok && pad == 0 {
+			return p
+		}
+		p := uint32(len(out))
+		out = append(out, byte(len(str)))
+		out = append(out, str...)
+		for i := len(str); i < pad; i++ {
+			out = append(out, 0)
+		}
+		if pad == 0 {
+			pointers[str] = p
+		}
+		return p
+	}
+
+	writeRow := func(pos int, values map[string]string) {
+		country := map[string]uint32{}
+		for _, c := range binColumns {
+			p := int(c.Positions[dbType])
+			if p == 0 {
+				continue
+			}
+
+			value, ok := values[c.Key]
+			if !ok {
+				value = "-"
+			}
+
+			var field uint32
+			switch c.Kind {
+			case binFloat:
+				f, _ := strconv.ParseFloat(value, 32)
+				field = math.Float32bits(float32(f))
+			case binCountryCode, binCountryName:
+				code, name := values["country_code"], values["country_name"]
+				if !ok {
+					code, name = "-", "-"
+				}
+				if _, done := country[code]; !done {
+					country[code] = addString(code, 2)
+					addString(name, 1)
+				}
+				field = country[code]
+			default:
+				field = addString(value, 0)
+			}
+			le.PutUint32(out[pos+(p-2)*4:], field)
+		}
+	}
+
+	for i, r := range rows4 {
+		pos := ipv4Base - 1 + i*rowSize4
+		le.PutUint32(out[pos:], uint32(uint128FromAddr(netip.MustParseAddr(r.From)).Lo))
+		writeRow(pos+4, r.Values)
+	}
+	le.PutUint32(out[ipv4Base-1+len(rows4)*rowSize4:], math.MaxUint32)
+
+	for i, r := range rows6 {
+		pos := ipv6Base - 1 + i*rowSize6
+		u := uint128FromAddr(netip.MustParseAddr(r.From))
+		le.PutUint64(out[pos:], u.Lo)
+		le.PutUint64(out[pos+8:], u.Hi)
+		writeRow(pos+16, r.Values)
+	}
+	pos := ipv6Base - 1 + len(rows6)*rowSize6
+	le.PutUint64(out[pos:], math.MaxUint64)
+	le.PutUint64(out[pos+8:], math.MaxUint64)
+
+	if index {
+		// the first and last rows for each value of the top 16 bits
+		rowFor := func(rows []binTestRow, u uint128) uint32 {
+			i := 0
+			for i+1 < len(rows) && uint128FromAddr(netip.MustParseAddr(rows[i+1].From)).Cmp(u) <= 0 {
+				i++
+			}
+			return uint32(i)
+		}
+		for k := uint64(0); k < 65536; k++ {
+			first4, last4 := uint128{0, k << 16}, uint128{0, k<<16 | 0xffff}
+			le.PutUint32(out[ipv4Index-1+int(k)*8:], rowFor(rows4, first4))
+			le.PutUint32(out[ipv4Index-1+int(k)*8+4:], rowFor(rows4, last4))
+
+			first6, last6 := uint128{k << 48, 0}, uint128{k<<48 | (1<<48 - 1), math.MaxUint64}
+			le.PutUint32(out[ipv6Index-1+int(k)*8:], rowFor(rows6, first6))
+			le.PutUint32(out[ipv6Index-1+int(k)*8+4:], rowFor(rows6, last6))
+		}
+	}
+
+	return out
+}
+
+// returns the path of the BIN database written to a temporary directory.
+func writeBinDB(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.BIN")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBinDBFixture(t *testing.T) {
+	path := filepath.Join("testdata", "IP2LOCATION-DB26.BIN")
+	data := buildBinDB(26, binTestRows4, binTestRows6, false)
+
+	if *update {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(data, want) {
+		t.Errorf("%s is not up to date, run go test -update", path)
+	}
+}
+
+func TestBinDBLookUp(t *testing.T) {
+	apiResult, err := os.ReadFile(filepath.Join("testdata", "api", "8.8.8.8.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	api, _ := JSONToMap(string(apiResult))
+
+	for _, index := range []bool{false, true} {
+		db, err := OpenBinDB(writeBinDB(t, buildBinDB(26, binTestRows4, binTestRows6, index)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		// the fields are the same as the API result
+		// the IPv4-mapped, 6to4 and Teredo addresses are looked up with their IPv4 address
+		for _, ip := range []string{"8.8.8.8", "8.8.8.0", "8.8.8.255", "::ffff:8.8.8.8", "2002:808:808::1", "2001:0:4136:e378:8000:63bf:f7f7:f7f7"} {
+			res, err := db.LookUp(ip)
+			if err != nil {
+				t.Fatalf("LookUp(%q) error = %v", ip, err)
+			}
+			got, _ := JSONToMap(res)
+			for key, value := range got {
+				// the coordinates are float32 in the database
+				if key == "latitude" || key == "longitude" {
+					if math.Abs(value.(float64)-api[key].(float64)) > 1e-5 {
+						t.Errorf("LookUp(%q) %s = %v, want %v", ip, key, value, api[key])
+					}
+				} else if key != "ip" && value != api[key] {
+					t.Errorf("LookUp(%q) %s = %v, want %v", ip, key, value, api[key])
+				}
+			}
+			if len(got) != len(binColumns)+1 || got["ip"] != ip {
+				t.Errorf("LookUp(%q) = %s", ip, res)
+			}
+		}
+
+		tests := map[string]string{
+			"1.1.1.1":         `{"ip":"1.1.1.1","country_code":"AU","country_name":"Australia","region_name":"Queensland","city_name":"Brisbane","latitude":-27.46754,"longitude":153.02809,"zip_code":"4000","time_zone":"+10:00","asn":"13335","as":"CloudFlare Inc","isp":"APNIC and CloudFlare DNS Resolver Project","domain":"cloudflare.com","net_speed":"T1","idd_code":"61","area_code":"07","weather_station_code":"ASXX0016","weather_station_name":"Brisbane","mcc":"-","mnc":"-","mobile_brand":"-","elevation":28,"usage_type":"DCH/SES","address_type":"Anycast","district":"Brisbane","ads_category":"IAB19-11"}`,
+			"255.255.255.255": `{"ip":"255.255.255.255","country_code":"-","country_name":"-","region_name":"-","city_name":"-","latitude":0,"longitude":0,"zip_code":"-","time_zone":"-","asn":"-","as":"-","isp":"-","domain":"-","net_speed":"-","idd_code":"-","area_code":"-","weather_station_code":"-","weather_station_name":"-","mcc":"-","mnc":"-","mobile_brand":"-","elevation":0,"usage_type":"-","address_type":"-","district":"-","ads_category":"-"}`,
+		}
+		for ip, want := range tests {
+			if got, err := db.LookUp(ip); err != nil || got != want {
+				t.Errorf("LookUp(%q) = %s, %v, want %s", ip, got, err, want)
+			}
+		}
+
+		for _, ip := range []string{"2001:4860::8888", "2001:4860:ffff::1"} {
+			if got, err := db.LookUp(ip); err != nil || !bytes.Contains([]byte(got), []byte(`"as":"Google LLC"`)) {
+				t.Errorf("LookUp(%q) = %s, %v", ip, got, err)
+			}
+		}
+		for _, ip := range []string{"2001:4861::1", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", "::"} {
+			if got, err := db.LookUp(ip); err != nil || !bytes.Contains([]byte(got), []byte(`"as":"-"`)) {
+				t.Errorf("LookUp(%q) = %s, %v", ip, got, err)
+			}
+		}
+
+		if _, err := db.LookUp("x"); err == nil {
+			t.Error(`LookUp("x") error = nil, want error`)
+		}
+	}
+}
+
+func TestBinDBNoData(t *testing.T) {
+	tests := []struct {
+		rows4 []binTestRow
+		rows6 []binTestRow
+		ip    string
+		want  string
+	}{
+		{binTestRows4, nil, "2001:4860::8888", "The database has no IPv6 data."},
+		{nil, binTestRows6, "8.8.8.8", "The database has no IPv4 data."},
+		{nil, binTestRows6, "2002:808:808::1", "The database has no IPv4 data."},
+		{binTestRows4[3:], nil, "1.1.1.1", "IP address not found in the database."},
+	}
+
+	for _, tt := range tests {
+		db, err := OpenBinDB(writeBinDB(t, buildBinDB(1, tt.rows4, tt.rows6, false)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		if _, err := db.LookUp(tt.ip); err == nil || err.Error() != tt.want {
+			t.Errorf("LookUp(%q) error = %v, want %s", tt.ip, err, tt.want)
+		}
+	}
+}
+
+func TestBinDBTypes(t *testing.T) {
+	// DB1 has the country and DB5 the city and coordinates
+	tests := map[uint8]string{
+		1: `{"ip":"8.8.8.8","country_code":"US","country_name":"United States of America"}`,
+		5: `{"ip":"8.8.8.8","country_code":"US","country_name":"United States of America","region_name":"California","city_name":"Mountain View","latitude":37.40599,"longitude":-122.078514}`,
+	}
+
+	for dbType, want := range tests {
+		db, err := OpenBinDB(writeBinDB(t, buildBinDB(dbType, binTestRows4, nil, false)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		if got, err := db.LookUp("8.8.8.8"); err != nil || got != want {
+			t.Errorf("DB%d LookUp = %s, %v, want %s", dbType, got, err, want)
+		}
+		if _, err := db.LookUp("2001:4860::8888"); err == nil {
+			t.Errorf("DB%d LookUp IPv6 error = nil, want error", dbType)
+		}
+	}
+}
+
+func TestOpenBinDBInvalid(t *testing.T) {
+	for _, data := range [][]byte{nil, make([]byte, 64), []byte("IP2Location")} {
+		if _, err := OpenBinDB(writeBinDB(t, data)); err == nil {
+			t.Errorf("OpenBinDB(%q) error = nil, want error", data)
+		}
+	}
+	if _, err := OpenBinDB(filepath.Join("testdata", "missing.BIN")); err == nil {
+		t.Error("OpenBinDB(missing) error = nil, want error")
+	}
+}
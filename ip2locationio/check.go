@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The Policy struct stores the rules used by the check command
+// to decide whether an IP address should be allowed or denied.
+type Policy struct {
+	MaxFraudScore  *int     `json:"max_fraud_score"`
+	DenyProxy      bool     `json:"deny_proxy"`
+	DenyProxyTypes []string `json:"deny_proxy_types"`
+	DenyFlags      []string `json:"deny_flags"`
+	DenyCountries  []string `json:"deny_countries"`
+	AllowCountries []string `json:"allow_countries"`
+	DenyUsageTypes []string `json:"deny_usage_types"`
+}
+
+// The Verdict struct stores the outcome of evaluating a lookup against a policy.
+type Verdict struct {
+	IP      string   `json:"ip"`
+	Allow   bool     `json:"allow"`
+	Reasons []string `json:"reasons"`
+}
+
+// LoadPolicy reads the policy from the supplied JSON file.
+func LoadPolicy(path string) (Policy, error) {
+	var policy Policy
+
+	byteValue, err := os.ReadFile(path)
+	if err != nil {
+		return policy, err
+	}
+
+	if err := json.Unmarshal(byteValue, &policy); err != nil {
+		return policy, errors.New("Invalid policy file: " + err.Error())
+	}
+
+	return policy, nil
+}
+
+// Evaluate checks the lookup result against the policy and returns the verdict. A rule whose field is
+// not in the result, e.g. with a plan which does not return it, denies the IP address.
+func (p Policy) Evaluate(ipl map[string]interface{}) Verdict {
+	var verdict Verdict
+
+	if v, ok := ipl["ip"].(string); ok {
+		verdict.IP = v
+	}
+
+	missing := func(field string) {
+		verdict.Reasons = append(verdict.Reasons, field+" is missing")
+	}
+
+	if p.MaxFraudScore != nil {
+		if v, ok := ipl["fraud_score"].(float64); !ok {
+			missing("fraud_score")
+		} else if int(v) > *p.MaxFraudScore {
+			verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("fraud_score %d exceeds %d", int(v), *p.MaxFraudScore))
+		}
+	}
+
+	if p.DenyProxy {
+		if v, ok := ipl["is_proxy"].(bool); !ok {
+			missing("is_proxy")
+		} else if v {
+			verdict.Reasons = append(verdict.Reasons, "is_proxy is true")
+		}
+	}
+
+	proxy, _ := ipl["proxy"].(map[string]interface{})
+
+	if len(p.DenyProxyTypes) > 0 {
+		if v, ok := proxy["proxy_type"].(string); !ok {
+			missing("proxy.proxy_type")
+		} else if containsFold(p.DenyProxyTypes, v) {
+			verdict.Reasons = append(verdict.Reasons, "proxy_type "+v+" is denied")
+		}
+	}
+
+	for _, flag := range p.DenyFlags {
+		flag = strings.TrimPrefix(flag, "proxy.")
+		if v, ok := proxy[flag].(bool); !ok {
+			missing("proxy." + flag)
+		} else if v {
+			verdict.Reasons = append(verdict.Reasons, "proxy."+flag+" is true")
+		}
+	}
+
+	if len(p.DenyCountries) > 0 || len(p.AllowCountries) > 0 {
+		if v, ok := ipl["country_code"].(string); !ok {
+			missing("country_code")
+		} else {
+			if containsFold(p.DenyCountries, v) {
+				verdict.Reasons = append(verdict.Reasons, "country_code "+v+" is in the deny list")
+			}
+			if len(p.AllowCountries) > 0 && !containsFold(p.AllowCountries, v) {
+				verdict.Reasons = append(verdict.Reasons, "country_code "+v+" is not in the allow list")
+			}
+		}
+	}
+
+	if len(p.DenyUsageTypes) > 0 {
+		if v, ok := ipl["usage_type"].(string); !ok {
+			missing("usage_type")
+		} else {
+			// usage types can be combined such as "DCH/SES"
+			for _, u := range strings.Split(v, "/") {
+				if containsFold(p.DenyUsageTypes, u) {
+					verdict.Reasons = append(verdict.Reasons, "usage_type "+u+" is denied")
+				}
+			}
+		}
+	}
+
+	verdict.Allow = len(verdict.Reasons) == 0
+
+	return verdict
+}
+
+// returns true if the list contains the value, ignoring case.
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintCheck evaluates the lookup for the IP against the policy file
+// and returns the exit code: 0 for allow, 1 for deny and 2 for errors.
+func PrintCheck(policyFile string, ip string) int {
+	if policyFile == "" {
+		fmt.Println("Missing policy file.")
+		return 2
+	}
+
+	policy, err := LoadPolicy(policyFile)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	if ip == "" {
+		ip = MyPublicIP()
+	} else if !IsIPv4(ip) && !IsIPv6(ip) {
+		fmt.Println("Not a valid IP address.")
+		return 2
+	}
+
+	ipl, err := lookUpResultMap(ip)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	verdict := policy.Evaluate(ipl)
+
+	if outputFormat == "pretty" {
+		if verdict.Allow {
+			fmt.Printf("%s: allow\n", verdict.IP)
+		} else {
+			fmt.Printf("%s: deny\n", verdict.IP)
+			for _, reason := range verdict.Reasons {
+				fmt.Printf("  - %s\n", reason)
+			}
+		}
+	} else {
+		if verdict.Reasons == nil {
+			verdict.Reasons = []string{}
+		}
+		byteValue, err := json.Marshal(&verdict)
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		fmt.Printf("%s\n", byteValue)
+	}
+
+	if !verdict.Allow {
+		return 1
+	}
+	return 0
+}
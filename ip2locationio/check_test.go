@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// returns the lookup result from the API test data.
+func loadResult(t *testing.T, ip string) map[string]interface{} {
+	t.Helper()
+
+	byteValue, err := os.ReadFile("testdata/api/" + ip + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ipl map[string]interface{}
+	if err := json.Unmarshal(byteValue, &ipl); err != nil {
+		t.Fatal(err)
+	}
+	return ipl
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	google := loadResult(t, "8.8.8.8")
+	cloudflare := loadResult(t, "1.1.1.1")
+	score := 70
+	// a result without the fields of the rules, e.g. from a lower plan or truncated
+	partial := map[string]interface{}{"ip": "8.8.8.8", "country_code": "US"}
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		ipl     map[string]interface{}
+		reasons []string
+	}{
+		{"empty policy", Policy{}, cloudflare, nil},
+		{"fraud score", Policy{MaxFraudScore: &score}, cloudflare, []string{"fraud_score 85 exceeds 70"}},
+		{"fraud score below", Policy{MaxFraudScore: &score}, google, nil},
+		{"proxy", Policy{DenyProxy: true}, cloudflare, []string{"is_proxy is true"}},
+		{"proxy type", Policy{DenyProxyTypes: []string{"tor", "vpn"}}, cloudflare, []string{"proxy_type VPN is denied"}},
+		{"flags", Policy{DenyFlags: []string{"proxy.is_vpn", "is_data_center"}}, google, []string{"proxy.is_data_center is true"}},
+		{"deny country", Policy{DenyCountries: []string{"au"}}, cloudflare, []string{"country_code AU is in the deny list"}},
+		{"allow country", Policy{AllowCountries: []string{"US"}}, cloudflare, []string{"country_code AU is not in the allow list"}},
+		{"usage type", Policy{DenyUsageTypes: []string{"SES"}}, cloudflare, []string{"usage_type SES is denied"}},
+		{"missing fraud score", Policy{MaxFraudScore: &score}, partial, []string{"fraud_score is missing"}},
+		{"missing proxy", Policy{DenyProxy: true, DenyProxyTypes: []string{"VPN"}, DenyFlags: []string{"is_tor"}}, partial, []string{"is_proxy is missing", "proxy.proxy_type is missing", "proxy.is_tor is missing"}},
+		{"missing usage type", Policy{DenyUsageTypes: []string{"SES"}, AllowCountries: []string{"US"}}, partial, []string{"usage_type is missing"}},
+		{"unknown flag", Policy{DenyFlags: []string{"is_unknown"}}, google, []string{"proxy.is_unknown is missing"}},
+	}
+
+	for _, tt := range tests {
+		verdict := tt.policy.Evaluate(tt.ipl)
+		if !reflect.DeepEqual(verdict.Reasons, tt.reasons) || verdict.Allow != (tt.reasons == nil) {
+			t.Errorf("%s: got %v %v, want %v", tt.name, verdict.Allow, verdict.Reasons, tt.reasons)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var cidrOperations = []string{"union", "intersect", "exclude"}
+
+// CIDRSet returns the minimal list of CIDRs for the set operation on the lists of CIDRs,
+// ranges and IP addresses. The union and intersect operations combine all the lists,
+// while exclude removes the other lists from the first one.
+func CIDRSet(operation string, lists [][]string) ([]string, error) {
+	operation = strings.ToLower(operation)
+	if err := checkCIDROperation(operation); err != nil {
+		return nil, err
+	} else if len(lists) == 0 {
+		return nil, errors.New("No CIDR, range or IP address supplied.")
+	}
+
+	sets := make([][]ipRange, len(lists))
+	for i, list := range lists {
+		ranges, err := ParseIPRanges(list)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = mergeRanges(ranges)
+	}
+
+	res := sets[0]
+	for _, set := range sets[1:] {
+		switch operation {
+		case "union":
+			res = mergeRanges(append(res, set...))
+		case "intersect":
+			res = intersectRanges(res, set)
+		case "exclude":
+			res = excludeRanges(res, set)
+		}
+	}
+
+	var cidrs []string
+	for _, r := range res {
+		cidrs = append(cidrs, r.CIDRs()...)
+	}
+
+	return cidrs, nil
+}
+
+// returns an error if the operation is not one of the cidr operations.
+func checkCIDROperation(operation string) error {
+	for _, op := range cidrOperations {
+		if op == operation {
+			return nil
+		}
+	}
+	return errors.New("Invalid operation. Valid values: " + strings.Join(cidrOperations, " | "))
+}
+
+// returns true if the range a is in an earlier family than b or ends before b starts.
+func rangeBefore(a ipRange, b ipRange) bool {
+	if a.IPv6 != b.IPv6 {
+		return !a.IPv6
+	}
+	return a.End.Cmp(b.Start) < 0
+}
+
+// returns the ranges in both the merged lists of ranges.
+func intersectRanges(a []ipRange, b []ipRange) []ipRange {
+	var res []ipRange
+
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		x, y := a[i], b[j]
+
+		if x.IPv6 == y.IPv6 {
+			start, end := x.Start, x.End
+			if y.Start.Cmp(start) > 0 {
+				start = y.Start
+			}
+			if y.End.Cmp(end) < 0 {
+				end = y.End
+			}
+			if start.Cmp(end) <= 0 {
+				res = append(res, ipRange{start, end, x.IPv6})
+			}
+		}
+
+		// advance the range which ends first
+		if rangeBefore(x, y) || (x.IPv6 == y.IPv6 && x.End.Cmp(y.End) <= 0) {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return res
+}
+
+// returns the ranges in the merged list a which are not in the merged list b.
+func excludeRanges(a []ipRange, b []ipRange) []ipRange {
+	var res []ipRange
+	one := uint128{0, 1}
+	j := 0
+
+	for _, x := range a {
+		for j < len(b) && rangeBefore(b[j], x) {
+			j++
+		}
+
+		start := x.Start
+		covered := false
+		for k := j; k < len(b) && b[k].IPv6 == x.IPv6 && b[k].Start.Cmp(x.End) <= 0; k++ {
+			y := b[k]
+			if y.Start.Cmp(start) > 0 {
+				res = append(res, ipRange{start, y.Start.Sub(one), x.IPv6})
+			}
+			if y.End.Cmp(x.End) >= 0 {
+				covered = true
+				break
+			}
+			start, _ = y.End.Add(one)
+		}
+
+		if !covered {
+			res = append(res, ipRange{start, x.End, x.IPv6})
+		}
+	}
+
+	return res
+}
+
+// ReadList returns the CIDRs, ranges or IP addresses in the list, which is either separated by comma,
+// "@FILE" to read one entry per line from the file or "-" to read from the standard input.
+func ReadList(list string) ([]string, error) {
+	if list == "-" {
+		return readLines(os.Stdin)
+	} else if strings.HasPrefix(list, "@") {
+		return readFileLines(list[1:])
+	}
+
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// checkStdin returns an error if more than one of the lists is "-", as standard input can only be read once.
+func checkStdin(lists []string) error {
+	n := 0
+	for _, list := range lists {
+		if list == "-" {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.New("Standard input can only be read once.")
+	}
+	return nil
+}
+
+func PrintCIDRSet(args []string) int {
+	operation := strings.ToLower(arg(args, 0))
+	if err := checkCIDROperation(operation); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if err := checkStdin(args[1:]); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	var lists [][]string
+	for _, list := range args[1:] {
+		entries, err := ReadList(list)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		lists = append(lists, entries)
+	}
+
+	res, err := CIDRSet(operation, lists)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	for _, element := range res {
+		fmt.Println(element)
+	}
+	return 0
+}
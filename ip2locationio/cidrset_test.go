@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCIDRSet(t *testing.T) {
+	tests := []struct {
+		operation string
+		lists     [][]string
+		want      []string
+		wantErr   bool
+	}{
+		{"union", [][]string{{"10.0.0.0/25"}, {"10.0.0.128/25", "2001:db8::/32"}}, []string{"10.0.0.0/24", "2001:db8::/32"}, false},
+		{"union", [][]string{{"10.0.0.0/24", "10.0.0.0/25"}}, []string{"10.0.0.0/24"}, false},
+		{"intersect", [][]string{{"10.0.0.0/8", "2001:db8::/32"}, {"10.1.0.0/16", "2001:db8:1::/48", "192.168.0.0/16"}}, []string{"10.1.0.0/16", "2001:db8:1::/48"}, false},
+		{"intersect", [][]string{{"10.0.0.0-10.0.0.9"}, {"10.0.0.8-10.0.0.20"}, {"10.0.0.0/8"}}, []string{"10.0.0.8/31"}, false},
+		{"intersect", [][]string{{"10.0.0.0/24"}, {"2001:db8::/32"}}, nil, false},
+		{"exclude", [][]string{{"10.0.0.0/16"}, {"10.0.0.0/24", "10.0.8.0-10.0.15.255"}}, []string{"10.0.1.0/24", "10.0.2.0/23", "10.0.4.0/22", "10.0.16.0/20", "10.0.32.0/19", "10.0.64.0/18", "10.0.128.0/17"}, false},
+		{"exclude", [][]string{{"10.0.0.0/24", "2001:db8::/32"}, {"2001:db8::/33"}, {"10.0.0.0/25"}}, []string{"10.0.0.128/25", "2001:db8:8000::/33"}, false},
+		{"exclude", [][]string{{"10.0.0.0/24"}, {"10.0.0.0/16"}}, nil, false},
+		{"exclude", [][]string{{"::/0"}, {"::/1"}}, []string{"8000::/1"}, false},
+		{"EXCLUDE", [][]string{{"10.0.0.0/31"}, {"10.0.0.1"}}, []string{"10.0.0.0/32"}, false},
+		{"subtract", [][]string{{"10.0.0.0/8"}}, nil, true},
+		{"union", nil, nil, true},
+		{"union", [][]string{{"10.0.0.0/8", "x"}}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := CIDRSet(tt.operation, tt.lists)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CIDRSet(%q, %v) = %v, %v, want %v", tt.operation, tt.lists, got, err, tt.want)
+		}
+	}
+}
+
+// returns the ranges for the pairs of bytes, used as the last octet of 10.0.0.x.
+func fuzzRanges(b []byte) []ipRange {
+	var res []ipRange
+	for i := 0; i+1 < len(b); i = i + 2 {
+		start, end := uint64(0x0a000000|uint32(b[i])), uint64(0x0a000000|uint32(b[i+1]))
+		if start > end {
+			start, end = end, start
+		}
+		res = append(res, ipRange{uint128{0, start}, uint128{0, end}, false})
+	}
+	return mergeRanges(res)
+}
+
+func FuzzCIDRSet(f *testing.F) {
+	f.Add([]byte{0, 255}, []byte{10, 20, 30, 40})
+	f.Add([]byte{5, 5, 7, 9}, []byte{0, 6})
+
+	f.Fuzz(func(t *testing.T, x []byte, y []byte) {
+		a, b := fuzzRanges(x), fuzzRanges(y)
+
+		// a is split into the part in b and the part not in b
+		inter := intersectRanges(a, b)
+		excl := excludeRanges(a, b)
+		if got := mergeRanges(append(append([]ipRange(nil), inter...), excl...)); !reflect.DeepEqual(got, a) && (len(got) != 0 || len(a) != 0) {
+			t.Fatalf("intersect %v + exclude %v = %v, want %v", inter, excl, got, a)
+		}
+		if len(intersectRanges(excl, b)) != 0 {
+			t.Fatalf("exclude %v overlaps %v", excl, b)
+		}
+		if !reflect.DeepEqual(intersectRanges(a, b), intersectRanges(b, a)) {
+			t.Fatalf("intersect is not symmetric for %v and %v", a, b)
+		}
+	})
+}
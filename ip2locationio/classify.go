@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"text/tabwriter"
+)
+
+// The specialRange struct stores an entry of the IANA IPv4 and IPv6 special-purpose address registries.
+type specialRange struct {
+	CIDR string
+	Type string
+	Name string
+	RFC  string
+}
+
+// the special-purpose address blocks from the IANA registries, plus multicast and the reserved IPv4 class E
+var specialRanges = []specialRange{
+	{"0.0.0.0/8", "this-network", "\"This network\"", "RFC 791"},
+	{"0.0.0.0/32", "this-host", "\"This host on this network\"", "RFC 1122"},
+	{"10.0.0.0/8", "private", "Private-Use", "RFC 1918"},
+	{"100.64.0.0/10", "cgnat", "Shared Address Space", "RFC 6598"},
+	{"127.0.0.0/8", "loopback", "Loopback", "RFC 1122"},
+	{"169.254.0.0/16", "link-local", "Link Local", "RFC 3927"},
+	{"172.16.0.0/12", "private", "Private-Use", "RFC 1918"},
+	{"192.0.0.0/24", "ietf-protocol", "IETF Protocol Assignments", "RFC 6890"},
+	{"192.0.0.0/29", "ds-lite", "IPv4 Service Continuity Prefix", "RFC 7335"},
+	{"192.0.0.8/32", "dummy", "IPv4 dummy address", "RFC 7600"},
+	{"192.0.0.9/32", "anycast", "Port Control Protocol Anycast", "RFC 7723"},
+	{"192.0.0.10/32", "anycast", "Traversal Using Relays around NAT Anycast", "RFC 8155"},
+	{"192.0.0.170/31", "nat64", "NAT64/DNS64 Discovery", "RFC 7050"},
+	{"192.0.2.0/24", "documentation", "Documentation (TEST-NET-1)", "RFC 5737"},
+	{"192.31.196.0/24", "as112", "AS112-v4", "RFC 7535"},
+	{"192.52.193.0/24", "amt", "AMT", "RFC 7450"},
+	{"192.88.99.0/24", "6to4-relay", "Deprecated (6to4 Relay Anycast)", "RFC 7526"},
+	{"192.168.0.0/16", "private", "Private-Use", "RFC 1918"},
+	{"192.175.48.0/24", "as112", "Direct Delegation AS112 Service", "RFC 7534"},
+	{"198.18.0.0/15", "benchmarking", "Benchmarking", "RFC 2544"},
+	{"198.51.100.0/24", "documentation", "Documentation (TEST-NET-2)", "RFC 5737"},
+	{"203.0.113.0/24", "documentation", "Documentation (TEST-NET-3)", "RFC 5737"},
+	{"224.0.0.0/4", "multicast", "Multicast", "RFC 5771"},
+	{"240.0.0.0/4", "reserved", "Reserved", "RFC 1112"},
+	{"255.255.255.255/32", "broadcast", "Limited Broadcast", "RFC 919"},
+	{"::/128", "unspecified", "Unspecified Address", "RFC 4291"},
+	{"::1/128", "loopback", "Loopback Address", "RFC 4291"},
+	{"::ffff:0:0/96", "ipv4-mapped", "IPv4-mapped Address", "RFC 4291"},
+	{"64:ff9b::/96", "nat64", "IPv4-IPv6 Translation", "RFC 6052"},
+	{"64:ff9b:1::/48", "nat64", "IPv4-IPv6 Translation", "RFC 8215"},
+	{"100::/64", "discard", "Discard-Only Address Block", "RFC 6666"},
+	{"2001::/23", "ietf-protocol", "IETF Protocol Assignments", "RFC 2928"},
+	{"2001::/32", "teredo", "TEREDO", "RFC 4380"},
+	{"2001:1::1/128", "anycast", "Port Control Protocol Anycast", "RFC 7723"},
+	{"2001:1::2/128", "anycast", "Traversal Using Relays around NAT Anycast", "RFC 8155"},
+	{"2001:2::/48", "benchmarking", "Benchmarking", "RFC 5180"},
+	{"2001:3::/32", "amt", "AMT", "RFC 7450"},
+	{"2001:4:112::/48", "as112", "AS112-v6", "RFC 7535"},
+	{"2001:10::/28", "orchid", "Deprecated (previously ORCHID)", "RFC 4843"},
+	{"2001:20::/28", "orchid", "ORCHIDv2", "RFC 7343"},
+	{"2001:db8::/32", "documentation", "Documentation", "RFC 3849"},
+	{"2002::/16", "6to4", "6to4", "RFC 3056"},
+	{"2620:4f:8000::/48", "as112", "Direct Delegation AS112 Service", "RFC 7534"},
+	{"3fff::/20", "documentation", "Documentation", "RFC 9637"},
+	{"5f00::/16", "srv6", "Segment Routing (SRv6) SIDs", "RFC 9602"},
+	{"fc00::/7", "unique-local", "Unique-Local", "RFC 4193"},
+	{"fe80::/10", "link-local", "Link-Local Unicast", "RFC 4291"},
+	{"ff00::/8", "multicast", "Multicast", "RFC 4291"},
+}
+
+var specialModes = []string{"skip", "annotate"}
+
+var specialTrie *PrefixTrie
+var specialByCIDR map[string]specialRange
+
+func init() {
+	specialTrie = &PrefixTrie{}
+	specialByCIDR = make(map[string]specialRange)
+
+	for _, s := range specialRanges {
+		p := netip.MustParsePrefix(s.CIDR)
+		start := uint128FromAddr(p.Addr())
+		width := p.Addr().BitLen()
+		specialTrie.Insert(ipRange{start, start.Or(lowMask(width - p.Bits())), p.Addr().Is6()}, s.CIDR)
+		specialByCIDR[s.CIDR] = s
+	}
+}
+
+// The Classification struct stores the special-purpose address block an IP address is in.
+type Classification struct {
+	IP         string `json:"ip"`
+	Type       string `json:"type"`
+	Name       string `json:"name,omitempty"`
+	CIDR       string `json:"cidr,omitempty"`
+	RFC        string `json:"rfc,omitempty"`
+	IsSpecial  bool   `json:"is_special"`
+	IPv4Mapped bool   `json:"ipv4_mapped,omitempty"`
+}
+
+// Classify returns the most specific special-purpose address block the IP address is in,
+// or the type "public" if there is none. IPv4-mapped IPv6 addresses are classified by their IPv4 address.
+func Classify(ip string) (Classification, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Zone() != "" {
+		return Classification{}, errors.New("Not a valid IP address.")
+	}
+
+	mapped := addr.Is4In6()
+	s, ok := classifyAddr(addr.Unmap())
+	if !ok {
+		return Classification{IP: ip, Type: "public", IPv4Mapped: mapped}, nil
+	}
+
+	return Classification{ip, s.Type, s.Name, s.CIDR, s.RFC, true, mapped}, nil
+}
+
+// returns the most specific special-purpose address block containing the address.
+func classifyAddr(addr netip.Addr) (specialRange, bool) {
+	u := uint128FromAddr(addr)
+
+	cidr, ok := specialTrie.Lookup(ipRange{u, u, addr.Is6()})
+	if !ok {
+		return specialRange{}, false
+	}
+	return specialByCIDR[cidr], true
+}
+
+// returns true if the address is in a special-purpose address block.
+func isSpecial(addr netip.Addr) bool {
+	_, ok := classifyAddr(addr)
+	return ok
+}
+
+// returns true if all the addresses from lo to hi are in one special-purpose address block.
+func isSpecialRange(lo uint128, hi uint128, ipv6 bool) bool {
+	_, ok := specialTrie.Lookup(ipRange{lo, hi, ipv6})
+	return ok
+}
+
+func PrintClassify(args []string) int {
+	ips, err := ReadIPs(args)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	var w *tabwriter.Writer
+	if outputFormat != "json" {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IP\tTYPE\tCIDR\tNAME\tRFC")
+		defer w.Flush()
+	}
+
+	for _, ip := range ips {
+		c, err := Classify(ip)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		typ := c.Type
+		if c.IPv4Mapped {
+			typ += " (ipv4-mapped)"
+		}
+
+		if w != nil && c.IsSpecial {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.IP, typ, c.CIDR, c.Name, c.RFC)
+			continue
+		} else if w != nil {
+			fmt.Fprintf(w, "%s\t%s\t-\t-\t-\n", c.IP, typ)
+			continue
+		}
+
+		byteValue, err := json.Marshal(c)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Printf("%s\n", byteValue)
+	}
+	return 0
+}
+
+// returns the result to output instead of looking up the IP address if it is in a special-purpose
+// address block and --special is used, where an empty result means the IP address is skipped.
+func specialLookup(ip string) (string, bool) {
+	if specialMode == "" {
+		return "", false
+	}
+
+	c, err := Classify(ip)
+	if err != nil || !c.IsSpecial {
+		return "", false
+	}
+
+	if specialMode == "skip" {
+		printLookupError(ip, errors.New("Skipped "+c.Type+" address."))
+		return "", true
+	}
+
+	byteValue, _ := json.Marshal(c)
+	return string(byteValue), true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := map[string]string{
+		"8.8.8.8":            "public",
+		"2606:4700::1111":    "public",
+		"10.1.2.3":           "private",
+		"172.31.255.255":     "private",
+		"172.32.0.0":         "public",
+		"192.168.0.1":        "private",
+		"100.64.0.1":         "cgnat",
+		"100.128.0.0":        "public",
+		"127.0.0.1":          "loopback",
+		"169.254.1.1":        "link-local",
+		"224.0.0.251":        "multicast",
+		"192.0.2.1":          "documentation",
+		"198.51.100.1":       "documentation",
+		"203.0.113.255":      "documentation",
+		"198.19.255.255":     "benchmarking",
+		"0.0.0.0":            "this-host",
+		"0.1.2.3":            "this-network",
+		"192.0.0.1":          "ds-lite",
+		"192.0.0.9":          "anycast",
+		"192.0.0.100":        "ietf-protocol",
+		"240.0.0.1":          "reserved",
+		"255.255.255.255":    "broadcast",
+		"::":                 "unspecified",
+		"::1":                "loopback",
+		"::ffff:192.168.1.1": "private",
+		"::ffff:8.8.8.8":     "public",
+		"64:ff9b::8.8.8.8":   "nat64",
+		"fe80::1":            "link-local",
+		"fd12:3456::1":       "unique-local",
+		"ff02::1":            "multicast",
+		"2001::1":            "teredo",
+		"2001:db8::1":        "documentation",
+		"3fff::1":            "documentation",
+		"2002:c000:204::1":   "6to4",
+		"2001:2::1":          "benchmarking",
+		"2001:100::1":        "ietf-protocol",
+	}
+
+	for ip, want := range tests {
+		c, err := Classify(ip)
+		if err != nil || c.Type != want || c.IsSpecial != (want != "public") {
+			t.Errorf("Classify(%q) = %+v, %v, want type %q", ip, c, err, want)
+		}
+	}
+
+	// the IPv4-mapped addresses are flagged as well as classified by their IPv4 address
+	for ip, want := range map[string]bool{"::ffff:192.168.1.1": true, "::ffff:8.8.8.8": true, "192.168.1.1": false} {
+		if c, _ := Classify(ip); c.IPv4Mapped != want {
+			t.Errorf("Classify(%q).IPv4Mapped = %t, want %t", ip, c.IPv4Mapped, want)
+		}
+	}
+
+	for _, ip := range []string{"10.1.2", "fe80::1%eth0", "x"} {
+		if _, err := Classify(ip); err == nil {
+			t.Errorf("Classify(%q) error = nil, want error", ip)
+		}
+	}
+}
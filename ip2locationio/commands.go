@@ -0,0 +1,747 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The Command struct stores a subcommand with its own flags, usage text and examples.
+type Command struct {
+	Name     string
+	Aliases  []string
+	Summary  string
+	Args     string
+	Details  string
+	Examples []string
+	Shared   func(fs *flag.FlagSet) // the options shared with other commands, such as -o
+	Flags    func(fs *flag.FlagSet)
+	Run      func(args []string) int
+}
+
+var commands []*Command
+
+func init() {
+	commands = []*Command{
+		{
+			Name:    "lookup",
+			Shared:  addLookupFlags,
+			Summary: "Query IP geolocation",
+			Args:    "<IP ADDRESS>...",
+			Details: globalOptionsUsage + `
+    The lookup command is the default, so "EXE 8.8.8.8" is the same as "EXE lookup 8.8.8.8"
+`,
+			Examples: []string{
+				"EXE 8.8.8.8",
+				"EXE -o pretty -l fr 8.8.8.8",
+				"EXE -f country_code,city_name 8.8.8.8 1.1.1.1",
+				`cat ips.txt | EXE --where 'proxy.is_vpn' -f ip,proxy.proxy_type -`,
+			},
+			Run: RunLookup,
+		},
+		{
+			Name:     "config",
+			Summary:  "Store the API key",
+			Args:     "<API KEY>",
+			Examples: []string{"EXE config YOUR_API_KEY"},
+			Run: func(args []string) int {
+				return UpdateAPIKey(arg(args, 0))
+			},
+		},
+		{
+			Name:    "fields",
+			Shared:  addOutputFlag,
+			Summary: "List the available result fields for -f (optionally only those in the specified plan)",
+			Args:    "[PLAN]",
+			Details: `
+    PLAN                 Valid values: free | starter | plus | security
+`,
+			Examples: []string{"EXE -o pretty fields", "EXE fields security"},
+			Run: func(args []string) int {
+				return PrintFields(arg(args, 0))
+			},
+		},
+		{
+			Name:    "check",
+			Shared:  addCheckFlags,
+			Summary: "Check an IP against a risk policy (exit code 0 = allow, 1 = deny, 2 = error)",
+			Args:    "<POLICY FILE> <IP ADDRESS>",
+			Details: `
+    The policy file is a JSON file, e.g.
+    {"max_fraud_score": 70, "deny_proxy_types": ["TOR", "VPN"], "deny_countries": ["KP"]}
+
+    Supported rules: max_fraud_score, deny_proxy, deny_proxy_types, deny_flags,
+    deny_countries, allow_countries and deny_usage_types. A rule whose field is not in the
+    lookup result, e.g. max_fraud_score without the Security plan, denies the IP.
+`,
+			Examples: []string{"EXE check policy.json 8.8.8.8", "EXE -o pretty check policy.json 8.8.8.8"},
+			Run: func(args []string) int {
+				return PrintCheck(arg(args, 0), arg(args, 1))
+			},
+		},
+		{
+			Name:    "completion",
+			Summary: "Generate the shell completion script",
+			Args:    "<SHELL>",
+			Details: `
+    SHELL                Valid values: bash | zsh | fish
+`,
+			Examples: []string{"source <(EXE completion bash)", "source <(EXE completion zsh)", "EXE completion fish | source"},
+			Run: func(args []string) int {
+				return PrintCompletion(arg(args, 0))
+			},
+		},
+		{
+			Name:    "randip",
+			Summary: "Generate random IP addresses",
+			Details: `
+    The addresses are picked uniformly from the IPv4 address space, the IPv6 global unicast
+    addresses (2000::/3) with -6, or the CIDR or range with --within. Use --public-only to leave out
+    the special-purpose addresses (see the classify command), and --seed to generate the same
+    addresses on every run, e.g. for test data.
+`,
+			Examples: []string{"EXE randip", "EXE randip -n 10 -6 --public-only", "EXE randip -n 100 --within 10.0.0.0/8 --seed 42"},
+			Flags: func(fs *flag.FlagSet) {
+				fs.Uint64Var(&randCount, "n", 1, "Generate `N` addresses")
+				fs.BoolVar(&randIPv6, "6", false, "Generate IPv6 addresses")
+				fs.StringVar(&randWithin, "within", "", "Generate addresses in the `CIDR` or range")
+				fs.BoolVar(&randPublicOnly, "public-only", false, "Leave out the special-purpose addresses")
+				fs.StringVar(&randSeed, "seed", "", "Seed the generator with `N` for reproducible addresses")
+			},
+			Run: PrintRandIP,
+		},
+		{
+			Name:     "cidr2range",
+			Summary:  "Convert CIDR to range",
+			Args:     "<CIDR>",
+			Examples: []string{"EXE cidr2range 10.0.0.0/8", "EXE cidr2range 2001:db8::/32"},
+			Run: func(args []string) int {
+				return PrintCIDR2Range(arg(args, 0))
+			},
+		},
+		{
+			Name:     "range2cidr",
+			Summary:  "Convert range to CIDR",
+			Args:     "<START IP END IP | START-END>",
+			Details:  rangeDetails,
+			Examples: []string{"EXE range2cidr 10.0.0.0 10.0.3.255", "EXE range2cidr 2001:db8::-2001:db8::ffff"},
+			Run: func(args []string) int {
+				return PrintRange2CIDR(args)
+			},
+		},
+		{
+			Name:     "cidr2list",
+			Summary:  "List out the IPs in a CIDR",
+			Args:     "<CIDR>",
+			Details:  listDetails,
+			Examples: []string{"EXE cidr2list 192.168.1.0/28", "EXE cidr2list --offset 256 --limit 10 10.0.0.0/8", "EXE cidr2list --force 10.0.0.0/8"},
+			Flags:    addListFlags,
+			Run: func(args []string) int {
+				return PrintCIDR2List(arg(args, 0))
+			},
+		},
+		{
+			Name:     "range2list",
+			Summary:  "List out the IPs in a range",
+			Args:     "<START IP END IP | START-END>",
+			Details:  rangeDetails + listDetails,
+			Examples: []string{"EXE range2list 192.168.1.1 192.168.1.10", "EXE range2list 192.168.1.1-192.168.1.10", "EXE range2list --limit 5 2001:db8:: 2001:db8::ffff"},
+			Flags:    addListFlags,
+			Run:      PrintRange2List,
+		},
+		{
+			Name:    "convert",
+			Shared:  addOutputFlag,
+			Summary: "Convert IP addresses between decimal, hex, binary, octal, IPv6 and reverse DNS formats",
+			Args:    "<IP ADDRESS | NUMBER>...",
+			Details: `
+    IP ADDRESS           An IPv4 or IPv6 address, IPv4 address with hex parts starting with 0x or octal
+                         parts starting with 0 such as 010.8.8.010 or 0x7f.1 as read by inet_aton,
+                         IPv4-mapped IPv6 address or in-addr.arpa or ip6.arpa reverse DNS name
+    NUMBER               A decimal number, hex number such as 0x08080808 or binary number such as
+                         0b1000, which is IPv4 unless it is too large or more than 8 hex or 32
+                         binary digits long, or -6 is used
+
+    FORMAT               ip | decimal | hex | binary | octal | expanded | compressed | reverse |
+                         mapped, where octal and mapped are only for IPv4 and expanded and
+                         compressed only for IPv6
+
+    The IP addresses are written in all the formats, or only in the --to format. Use "-" to
+    convert one value per line from standard input as it is read, and -o pretty to print one
+    format per line.
+`,
+			Examples: []string{"EXE convert 8.8.8.8", "EXE convert --to ip 134744072", "EXE convert --to reverse 2001:db8::1", "EXE -o pretty convert ::ffff:192.168.1.1"},
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&convertTo, "to", "", "Only write the IP addresses in the `FORMAT`")
+				fs.BoolVar(&convertIPv6, "6", false, "Read the numbers as IPv6 addresses")
+			},
+			Run: PrintConvert,
+		},
+		{
+			Name:    "splitcidr",
+			Summary: "Split a larger CIDR into smaller ones",
+			Args:    "<CIDR> [SPLIT]",
+			Details: `
+    SPLIT                The prefix length of the smaller CIDRs
+
+    Instead of SPLIT, use --subnets to split into at least N subnets of the same size, which is
+    rounded up to a power of two, or --hosts to split into the smallest subnets with at least
+    H usable hosts. The network and broadcast addresses of IPv4 subnets are not usable hosts.
+    More than 1048576 subnets are only listed with --limit or --force.
+`,
+			Examples: []string{"EXE splitcidr 10.0.0.0/8 16", "EXE splitcidr --subnets 6 10.0.0.0/24", "EXE splitcidr --hosts 50 10.0.0.0/24"},
+			Flags: func(fs *flag.FlagSet) {
+				fs.Uint64Var(&splitSubnets, "subnets", 0, "Split into at least `N` subnets of the same size")
+				fs.Uint64Var(&splitHosts, "hosts", 0, "Split into the smallest subnets with at least `H` usable hosts")
+				addSubnetListFlags(fs)
+			},
+			Run: func(args []string) int {
+				return PrintSplitCIDR(arg(args, 0), arg(args, 1))
+			},
+		},
+		{
+			Name:    "subnet",
+			Shared:  addOutputFlag,
+			Aliases: []string{"ipcalc"},
+			Summary: "Show the network details of a CIDR",
+			Args:    "<CIDR | IP ADDRESS NETMASK>",
+			Details: `
+    NETMASK              A prefix length or a netmask such as 255.255.255.0
+
+    The output includes the network and broadcast addresses, netmask, wildcard mask, first and last
+    usable hosts, host counts, the IPv4 class and whether the network is private or reserved,
+    i.e. in a special-purpose address block (see the classify command).
+    Use -o pretty to print one detail per line.
+`,
+			Examples: []string{"EXE subnet 192.168.1.10/24", "EXE -o pretty ipcalc 192.168.1.10 255.255.255.0", "EXE subnet 2001:db8::/48"},
+			Run: func(args []string) int {
+				return PrintSubnet(arg(args, 0), arg(args, 1))
+			},
+		},
+		{
+			Name:    "vlsm",
+			Shared:  addOutputFlag,
+			Summary: "Allocate subnets of different sizes in a CIDR",
+			Args:    "<CIDR> <NAME:HOSTS>...",
+			Details: `
+    NAME:HOSTS           The name of a subnet and the number of usable hosts it needs
+
+    The smallest subnet is allocated for each requirement without overlaps, largest first, and the
+    free space left is listed as CIDRs. The network and broadcast addresses of IPv4 subnets are not
+    usable hosts. Use -o pretty to print a table.
+`,
+			Examples: []string{"EXE vlsm 10.0.0.0/24 sales:50 it:20 wan:2", "EXE -o pretty vlsm 192.168.0.0/22 office:200 lab:100 guest:60"},
+			Run:      PrintVLSM,
+		},
+		{
+			Name:    "freespace",
+			Summary: "List the free space in a CIDR which is not in the lists of CIDRs, ranges and IPs in use",
+			Args:    "<CIDR> <LIST>...",
+			Details: `
+    LIST                 CIDRs, ranges written as START-END or IP addresses separated by comma,
+                         "@FILE" to read one entry per line from the file or "-" for standard input
+
+    The free space is listed as the minimal CIDRs. Use --size to list the free subnets with the
+    prefix length instead, and --next to only list the first one. More than 1048576 subnets are
+    only listed with --limit or --force.
+`,
+			Examples: []string{
+				"EXE freespace 10.0.0.0/16 10.0.0.0/24,10.0.4.0/22",
+				"EXE freespace --size 24 --next 10.0.0.0/16 @used.txt",
+			},
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&freeSize, "size", "", "List the free subnets with the prefix length `N`")
+				fs.BoolVar(&freeNext, "next", false, "Only list the first free subnet with the --size prefix length")
+				addSubnetListFlags(fs)
+			},
+			Run: PrintFreeSpace,
+		},
+		{
+			Name:    "classify",
+			Shared:  addOutputFlag,
+			Summary: "Classify IPs as special-purpose addresses such as private, loopback or documentation ones",
+			Args:    "<IP ADDRESS>...",
+			Details: `
+    The special-purpose address blocks are from the IANA IPv4 and IPv6 Special-Purpose Address
+    Registries, plus multicast and the reserved IPv4 240.0.0.0/4. The type is one of public, private,
+    loopback, link-local, cgnat, multicast, documentation, benchmarking, unique-local, teredo, 6to4,
+    nat64, this-network, this-host, unspecified, broadcast, reserved, ietf-protocol, ds-lite, dummy,
+    anycast, as112, amt, 6to4-relay, discard, orchid and srv6. IPv4-mapped IPv6 addresses are
+    classified by their IPv4 address and flagged as ipv4_mapped.
+
+    Use "-" to read one IP address per line from standard input, and -o pretty to print a table.
+    Use --special with the lookup to skip or annotate these addresses instead of querying them.
+`,
+			Examples: []string{"EXE classify 10.1.2.3 100.64.0.1 2001:db8::1 8.8.8.8", "EXE -o pretty classify ::ffff:192.168.1.1", "EXE --special skip - < ips.txt"},
+			Run:      PrintClassify,
+		},
+		{
+			Name:    "decode",
+			Shared:  addOutputFlag,
+			Summary: "Decode the IPv4 address embedded in 6to4, Teredo, NAT64 and IPv4-mapped IPv6 addresses",
+			Args:    "<IPv6 ADDRESS>...",
+			Details: `
+    The mechanism is one of 6to4 (2002::/16), teredo (2001::/32), nat64 (64:ff9b::/96 and the
+    local-use 64:ff9b:1::/48, read as a /96 prefix), ipv4-mapped (::ffff:0:0/96) or none. For
+    Teredo, the IPv4 address is the client's public address and the Teredo server and the client's
+    port are included too.
+
+    Use "-" to read one IP address per line from standard input, and -o pretty to print a table.
+    Use --embedded with the lookup to geolocate the embedded IPv4 addresses instead.
+`,
+			Examples: []string{"EXE decode 2002:c000:204::1 2001:0:4136:e378:8000:63bf:3fff:fdd2", "EXE -o pretty decode 64:ff9b::8.8.8.8", "EXE --embedded 2002:808:808::1"},
+			Run:      PrintDecode,
+		},
+		{
+			Name:    "whois",
+			Shared:  addOutputFlag,
+			Summary: "Show the registration data of IPs and ASNs from RDAP",
+			Args:    "<IP ADDRESS | ASN>...",
+			Details: `
+    ASN                  An AS number with or without the AS prefix, e.g. AS15169 or 15169
+
+    The RDAP server of the registry is found from the IANA RDAP bootstrap files bundled with the
+    tool, and the output includes the network or ASN name, the allocated range and its CIDRs, the
+    registrant organization and the abuse contact email. Use -o pretty to print one detail per line.
+`,
+			Examples: []string{"EXE whois 8.8.8.8", "EXE -o pretty whois AS15169", "EXE whois --rdap-url http://localhost:8080/rdap/ 2001:db8::1"},
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&rdapBaseURL, "rdap-url", rdapBaseURL, "Query the RDAP server at the base `URL` instead of the registry's")
+			},
+			Run: PrintWhois,
+		},
+		{
+			Name:    "asn",
+			Shared:  addASNFlags,
+			Summary: "List the prefixes announced by an ASN in a routing table",
+			Args:    "<ASN>",
+			Details: `
+    ASN                  An AS number with or without the AS prefix, e.g. AS15169 or 15169
+
+    The routing table is a file with one route per line, written as "PREFIX ORIGIN", "PREFIX AS PATH",
+    "ADDRESS LENGTH ORIGIN" as in the CAIDA prefix-to-AS files, or as the output of bgpdump -m.
+    Files ending with .gz are decompressed. Use --geolocate to look up the first usable IP address
+    of each prefix, and -o pretty to print a table.
+`,
+			Examples: []string{"EXE asn --routes routeviews-rv2-pfx2as.txt AS15169", "EXE -o pretty asn --routes rib.txt.gz --geolocate 13335"},
+			Flags: func(fs *flag.FlagSet) {
+				fs.StringVar(&asnRoutes, "routes", "", "Read the routing table from the `FILE`")
+				fs.BoolVar(&asnGeolocate, "geolocate", false, "Look up an IP address of each prefix")
+			},
+			Run: PrintASN,
+		},
+		{
+			Name:    "aggregate",
+			Summary: "Merge CIDRs, ranges and IPs into the minimal list of CIDRs",
+			Args:    "<LIST>...",
+			Details: `
+    LIST                 CIDRs, ranges written as START-END or IP addresses separated by comma,
+                         "@FILE" to read one entry per line from the file or "-" for standard input
+
+    IPv4 and IPv6 can be mixed. Text after "#" in files and standard input is ignored.
+`,
+			Examples: []string{
+				"EXE aggregate 10.0.0.0/25 10.0.0.128/25 10.0.1.0-10.0.1.255",
+				"EXE aggregate @allowlist.txt",
+				"cat allowlist.txt | EXE aggregate -",
+			},
+			Run: PrintAggregate,
+		},
+		{
+			Name:    "cidr",
+			Summary: "Combine lists of CIDRs, ranges and IPs into the minimal list of CIDRs",
+			Args:    "<OPERATION> <LIST>...",
+			Details: `
+    OPERATION            Valid values: union | intersect | exclude
+                         union lists the IPs in any of the lists, intersect the IPs in all the lists
+                         and exclude the IPs in the first list which are not in the other lists
+
+    LIST                 CIDRs, ranges written as START-END or IP addresses separated by comma,
+                         "@FILE" to read one entry per line from the file or "-" for standard input
+`,
+			Examples: []string{
+				"EXE cidr exclude 10.0.0.0/16 10.0.0.0/24,10.0.8.0-10.0.15.255",
+				"EXE cidr union @office.txt @vpn.txt",
+				"cat blocklist.txt | EXE cidr intersect - 192.168.0.0/16",
+			},
+			Run: PrintCIDRSet,
+		},
+		{
+			Name:    "contains",
+			Summary: "Check if IPs or CIDRs are in a list of CIDRs, ranges and IPs (exit code 0 = found, 1 = not found, 2 = error)",
+			Args:    "<LIST> <IP ADDRESS | CIDR>...",
+			Details: `
+    LIST                 CIDRs, ranges written as START-END or IP addresses separated by comma,
+                         "@FILE" to read one entry per line from the file or "-" for standard input
+
+    Each IP address or CIDR found is written with the most specific entry of the list containing it.
+    Use "-" to check one IP address or CIDR per line from standard input as it is read.
+`,
+			Examples: []string{
+				"EXE contains 10.0.0.0/8,192.168.0.0/16 10.1.2.3",
+				"EXE contains @blocklist.txt 10.1.0.0/16 8.8.8.8",
+				"cut -d ' ' -f 1 access.log | EXE contains --invert @allowlist.txt -",
+			},
+			Flags: func(fs *flag.FlagSet) {
+				fs.BoolVar(&containsInvert, "invert", false, "Write the IP addresses or CIDRs which are not in the list instead")
+			},
+			Run: PrintContains,
+		},
+		{
+			Name:     "help",
+			Summary:  "Show the options and examples of a command",
+			Args:     "<COMMAND>",
+			Examples: []string{"EXE help splitcidr"},
+			Run:      RunHelp,
+		},
+	}
+}
+
+const listDetails string = `
+    The IP addresses are written as they are generated. Listing more than 1048576 IP addresses
+    is refused unless --force is used or --limit is set to a lower value.
+`
+
+const rangeDetails string = `
+    The range is either the start and end IP addresses or a single START-END argument as written by
+    cidr2range. The start and end must be both IPv4 or both IPv6, where IPv4-mapped IPv6 addresses
+    are IPv4 unless the other IP address is IPv6.
+`
+
+// registers the options of the cidr2list and range2list commands.
+func addListFlags(fs *flag.FlagSet) {
+	fs.Uint64Var(&listLimit, "limit", 0, "List at most `N` IP addresses (0 = no limit)")
+	fs.Uint64Var(&listOffset, "offset", 0, "Skip the first `N` IP addresses")
+	fs.BoolVar(&listForce, "force", false, "List all IP addresses even if there are more than 1048576")
+}
+
+// registers the options of the commands listing subnets.
+func addSubnetListFlags(fs *flag.FlagSet) {
+	fs.Uint64Var(&listLimit, "limit", 0, "List at most `N` subnets (0 = no limit)")
+	fs.BoolVar(&listForce, "force", false, "List all subnets even if there are more than 1048576")
+}
+
+// returns the argument at the index or an empty string if not supplied.
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// FindCommand returns the command with the name or nil if not found.
+func FindCommand(name string) *Command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+		for _, alias := range c.Aliases {
+			if alias == name {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// registers the options of all the commands, using the current values as defaults.
+func addGlobalFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&showVer, "v", showVer, "Show version")
+}
+
+// registers the output format option, using the current value as default.
+func addOutputFlag(fs *flag.FlagSet) {
+	fs.StringVar(&outputFormat, "o", outputFormat, "Output format: "+strings.Join(outputFormats, " | "))
+}
+
+// registers the options of the API requests, using the current values as defaults.
+func addAPIFlags(fs *flag.FlagSet) {
+	fs.StringVar(&apiKey, "k", apiKey, "API key: Get your API key from https://ip2location.io")
+	fs.StringVar(&myLanguage, "l", myLanguage, "Language: "+strings.Join(languages, " | "))
+}
+
+// registers the options of the check command.
+func addCheckFlags(fs *flag.FlagSet) {
+	addOutputFlag(fs)
+	addAPIFlags(fs)
+}
+
+// registers the options of the asn command used by --geolocate.
+func addASNFlags(fs *flag.FlagSet) {
+	addOutputFlag(fs)
+	addAPIFlags(fs)
+	fs.StringVar(&lookupDB, "db", lookupDB, "Look up the IP addresses in the IP2Location BIN database `FILE` instead of the API")
+}
+
+// registers the options of the lookup command, which are also accepted before the command name.
+func addLookupFlags(fs *flag.FlagSet) {
+	addOutputFlag(fs)
+	addAPIFlags(fs)
+	fs.StringVar(&filterFields, "f", filterFields, `Filter fields: Field names separted by comma. E.g., "country_code,city_name,continent.name,continent.hemisphere[0],country.*,isp as provider"`)
+	fs.StringVar(&whereExpr, "where", whereExpr, `Where expression: Only output results matching the expression. E.g., 'country_code == "US" && proxy.is_vpn'`)
+	fs.StringVar(&specialMode, "special", specialMode, "Special-purpose addresses: "+strings.Join(specialModes, " | "))
+	fs.BoolVar(&lookupEmbedded, "embedded", lookupEmbedded, "Look up the IPv4 address embedded in 6to4, Teredo, NAT64 and IPv4-mapped IPv6 addresses")
+	fs.BoolVar(&lookupRDNS, "rdns", lookupRDNS, "Add the reverse DNS names and whether they are forward-confirmed")
+	fs.StringVar(&rdnsResolver, "resolver", rdnsResolver, "DNS server address for --rdns")
+	fs.StringVar(&lookupDB, "db", lookupDB, "Look up the IP addresses in the IP2Location BIN database `FILE` instead of the API")
+	fs.BoolVar(&lookupHybrid, "hybrid", lookupHybrid, "Use the API for the result fields which are not in the --db database")
+	fs.IntVar(&hybridMaxAge, "max-age", hybridMaxAge, "Use the API in place of the --db database older than `DAYS` days for --hybrid")
+}
+
+// parses the flags, allowing them to be mixed with the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+
+		// everything after "--" is positional
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+
+	return positional, nil
+}
+
+// Run parses the global options and dispatches to the command, returning the exit code.
+func Run(args []string) int {
+	fs := flag.NewFlagSet(programName, flag.ContinueOnError)
+	addGlobalFlags(fs)
+	addLookupFlags(fs)
+	fs.Usage = PrintUsage
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if showVer {
+		PrintVersion()
+		return 0
+	}
+
+	name := fs.Arg(0)
+	rest := fs.Args()
+	c := FindCommand(name)
+
+	if c != nil {
+		rest = rest[1:]
+	} else if name == "" || name == "-" || strings.ContainsAny(name, ".:") || (name[0] >= '0' && name[0] <= '9') {
+		// IP addresses go to the default lookup command
+		c = FindCommand("lookup")
+	} else {
+		fmt.Printf("Unknown command %q.", name)
+		if suggestion := SuggestCommand(name); suggestion != "" {
+			fmt.Printf(" Did you mean %q?", suggestion)
+		}
+		fmt.Printf("\nRun \"%s -h\" for usage.\n", os.Args[0])
+		return 2
+	}
+
+	// the options before the command name must be options of the command
+	cfs := c.flagSet()
+	unknown := ""
+	fs.Visit(func(f *flag.Flag) {
+		if unknown == "" && cfs.Lookup(f.Name) == nil {
+			unknown = f.Name
+		}
+	})
+	if unknown != "" {
+		fmt.Fprintf(os.Stderr, "flag provided but not defined: -%s\n", unknown)
+		PrintCommandHelp(c)
+		return 2
+	}
+
+	return c.Execute(rest)
+}
+
+// returns the flag set with the options of the command.
+func (c *Command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	addGlobalFlags(fs)
+	if c.Shared != nil {
+		c.Shared(fs)
+	}
+	if c.Flags != nil {
+		c.Flags(fs)
+	}
+	return fs
+}
+
+// Execute parses the command options and runs the command, returning the exit code.
+func (c *Command) Execute(args []string) int {
+	fs := c.flagSet()
+	fs.Usage = func() {
+		PrintCommandHelp(c)
+	}
+
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	if showVer {
+		PrintVersion()
+		return 0
+	}
+
+	if apiKey == "" {
+		apiKey = config.APIKey
+	}
+
+	return c.Run(positional)
+}
+
+// UsageLine returns the usage of the command with its arguments.
+func (c *Command) UsageLine() string {
+	usage := "EXE [OPTION]... " + c.Name
+	if c.Name == "help" || c.Name == "config" || c.Name == "completion" {
+		usage = "EXE " + c.Name
+	}
+	if c.Args != "" {
+		usage = usage + " " + c.Args
+	}
+	return usage
+}
+
+// RunHelp prints the help of the command, or the overall usage if no command supplied.
+func RunHelp(args []string) int {
+	name := arg(args, 0)
+	if name == "" {
+		PrintUsage()
+		return 0
+	}
+
+	c := FindCommand(name)
+	if c == nil {
+		fmt.Printf("Unknown command %q.", name)
+		if suggestion := SuggestCommand(name); suggestion != "" {
+			fmt.Printf(" Did you mean %q?", suggestion)
+		}
+		fmt.Println("")
+		return 2
+	}
+
+	PrintCommandHelp(c)
+	return 0
+}
+
+func PrintCommandHelp(c *Command) {
+	fmt.Printf("%s Version %s\n", programName, version)
+
+	var sb strings.Builder
+	sb.WriteString("\nTo " + strings.ToLower(c.Summary[:1]) + c.Summary[1:] + "\n\n")
+	sb.WriteString("  Usage: " + c.UsageLine() + "\n")
+	if len(c.Aliases) > 0 {
+		sb.WriteString("\n  Aliases: " + strings.Join(c.Aliases, ", ") + "\n")
+	}
+
+	if c.Flags != nil {
+		sb.WriteString("\nOptions:\n")
+		fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+		c.Flags(fs)
+		writeFlags(&sb, fs)
+	}
+
+	if c.Details != "" {
+		sb.WriteString(c.Details)
+	}
+
+	if len(c.Examples) > 0 {
+		sb.WriteString("\nExamples:\n\n")
+		for _, example := range c.Examples {
+			sb.WriteString("  " + example + "\n")
+		}
+	}
+
+	fmt.Println(strings.ReplaceAll(sb.String(), "EXE", os.Args[0]))
+}
+
+// writes the flags in the same layout as the usage text.
+func writeFlags(w io.Writer, fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+
+		prefix := "-"
+		if len(f.Name) > 1 {
+			prefix = "--"
+		}
+		left := prefix + f.Name
+		if name != "" {
+			left = left + " " + name
+		}
+		if f.DefValue != "" && f.DefValue != "0" && f.DefValue != "false" {
+			usage = usage + " (default " + f.DefValue + ")"
+		}
+
+		fmt.Fprintf(w, "\n    %-20s %s\n", left, usage)
+	})
+}
+
+// SuggestCommand returns the command with the closest name, or an empty string if none is close enough.
+func SuggestCommand(name string) string {
+	best := ""
+	bestDistance := 3 // only suggest if at most 2 edits away
+
+	for _, c := range commands {
+		if strings.HasPrefix(c.Name, name) {
+			return c.Name
+		}
+		if d := levenshtein(name, c.Name); d < bestDistance {
+			best = c.Name
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+// returns the number of single-character edits to change a into b.
+func levenshtein(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			// the cheapest of deleting, inserting and substituting
+			curr[j] = prev[j] + 1
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+			if prev[j-1]+cost < curr[j] {
+				curr[j] = prev[j-1] + cost
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
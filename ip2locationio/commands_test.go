@@ -0,0 +1,378 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// the URL of the fake API, replaced with http://localhost in the golden files
+var serverURL string
+
+func TestMain(m *testing.M) {
+	server := httptest.NewServer(http.HandlerFunc(fakeAPI))
+	serverURL = server.URL
+	apiURL = server.URL
+	myIPURL = server.URL + "/get-ip.json"
+	rdapBaseURL = server.URL + "/rdap/"
+
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+// serves the lookup results from testdata/api in place of the IP2Location.io API,
+// and the RDAP responses from testdata/rdap, where ":" in the IPv6 file names is "-" to
+// keep them valid on Windows.
+func fakeAPI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/get-ip.json" {
+		w.Write([]byte(`{"IP":"8.8.8.8"}`))
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/rdap/") {
+		byteValue, err := os.ReadFile(filepath.Join("testdata", strings.ReplaceAll(r.URL.Path, ":", "-")+".json"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errorCode":404,"title":"Not Found"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		w.Write(byteValue)
+		return
+	}
+
+	if r.URL.Query().Get("key") == "invalid" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"error_code":10001,"error_message":"Invalid API key or insufficient credit."}}`))
+		return
+	}
+
+	byteValue, err := os.ReadFile(filepath.Join("testdata", "api", r.URL.Query().Get("ip")+".json"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"error_code":10000,"error_message":"Invalid IP address."}}`))
+		return
+	}
+	w.Write(byteValue)
+}
+
+// resets the options which are kept between runs.
+func resetOptions() {
+	outputFormat = "json"
+	apiKey = ""
+	myLanguage = ""
+	filterFields = ""
+	whereExpr = ""
+	specialMode = ""
+	lookupEmbedded = false
+	lookupRDNS = false
+	rdnsResolver = ""
+	lookupDB = ""
+	lookupHybrid = false
+	hybridMaxAge = 0
+	hybridFields = nil
+	showVer = false
+	myIPs = nil
+	listLimit = 0
+	listOffset = 0
+	listForce = false
+	containsInvert = false
+	splitSubnets = 0
+	splitHosts = 0
+	freeSize = ""
+	freeNext = false
+	randCount = 1
+	randIPv6 = false
+	randWithin = ""
+	randPublicOnly = false
+	randSeed = ""
+	convertTo = ""
+	convertIPv6 = false
+	asnRoutes = ""
+	asnGeolocate = false
+}
+
+// runs the command line and returns the standard output and exit code.
+func runCLI(t *testing.T, stdin string, args ...string) (string, int) {
+	t.Helper()
+	resetOptions()
+
+	// the usage text includes the program name
+	origName := os.Args[0]
+	os.Args[0] = "ip2locationio"
+	defer func() { os.Args[0] = origName }()
+
+	if stdin != "" {
+		f, err := os.Open(stdin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		origStdin := os.Stdin
+		os.Stdin = f
+		defer func() { os.Stdin = origStdin }()
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	origStdout := os.Stdout
+	os.Stdout = w
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	code := Run(args)
+
+	w.Close()
+	os.Stdout = origStdout
+
+	return <-out, code
+}
+
+func TestCommandsGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		stdin string
+		args  []string
+		code  int
+	}{
+		{"usage", "", []string{"-h"}, 0},
+		{"version", "", []string{"-v"}, 0},
+		{"unknown", "", []string{"splitcdr", "10.0.0.0/8", "16"}, 2},
+		{"help_splitcidr", "", []string{"help", "splitcidr"}, 0},
+		{"help_cidr2list", "", []string{"cidr2list", "-h"}, 0},
+		{"help_lookup", "", []string{"help", "lookup"}, 0},
+		{"lookup_json", "", []string{"8.8.8.8"}, 0},
+		{"lookup_pretty", "", []string{"8.8.8.8", "-o", "pretty"}, 0},
+		{"lookup_own_ip", "", []string{"-f", "ip,country_code"}, 0},
+		{"lookup_filter", "", []string{"-f", "ip,country_code,continent.hemisphere[0],country.currency.*,isp as provider,missing", "8.8.8.8", "1.1.1.1"}, 0},
+		{"lookup_where", "", []string{"--where", `proxy.is_vpn && country_code == "AU"`, "-f", "ip,proxy.proxy_type", "8.8.8.8", "1.1.1.1"}, 0},
+		{"lookup_where_invalid", "", []string{"--where", `country_code ==`, "8.8.8.8"}, 1},
+		{"lookup_stdin", "testdata/ips.txt", []string{"-f", "ip,city_name", "-"}, 0},
+		{"lookup_stdin_invalid", "testdata/ips_invalid.txt", []string{"-f", "ip,city_name", "-"}, 0},
+		{"lookup_special_skip", "", []string{"--special", "skip", "-f", "ip,country_code", "10.1.2.3", "8.8.8.8", "::1"}, 0},
+		{"lookup_special_skip_single", "", []string{"--special", "skip", "192.168.1.1"}, 0},
+		{"lookup_special_annotate", "", []string{"--special", "annotate", "10.1.2.3", "8.8.8.8"}, 0},
+		{"lookup_special_annotate_filter", "", []string{"--special", "annotate", "-f", "ip,country_code,type", "8.8.8.8", "100.64.0.1"}, 0},
+		{"lookup_special_invalid", "", []string{"--special", "ignore", "8.8.8.8"}, 1},
+		{"lookup_embedded", "", []string{"--embedded", "-f", "ip,queried_ip,country_code", "2002:808:808::1", "64:ff9b::1.1.1.1"}, 0},
+		{"lookup_embedded_json", "", []string{"--embedded", "2002:808:808::1"}, 0},
+		{"lookup_invalid_ip", "", []string{"1.2.3"}, 1},
+		{"lookup_api_error", "", []string{"9.9.9.9"}, 1},
+		{"lookup_invalid_key", "", []string{"-k", "invalid", "8.8.8.8"}, 1},
+		{"check_allow", "", []string{"check", "testdata/policy.json", "8.8.8.8"}, 0},
+		{"check_deny", "", []string{"-o", "pretty", "check", "testdata/policy.json", "1.1.1.1"}, 1},
+		{"check_missing_policy", "", []string{"check", "testdata/missing.json", "1.1.1.1"}, 2},
+		{"fields", "", []string{"fields", "free"}, 0},
+		{"fields_pretty", "", []string{"-o", "pretty", "fields"}, 0},
+		{"fields_invalid", "", []string{"fields", "gold"}, 1},
+		{"completion_bash", "", []string{"completion", "bash"}, 0},
+		{"completion_zsh", "", []string{"completion", "zsh"}, 0},
+		{"completion_fish", "", []string{"completion", "fish"}, 0},
+		{"completion_invalid", "", []string{"completion", "tcsh"}, 1},
+		{"cidr2list_lookup_option", "", []string{"cidr2list", "-f", "ip", "10.0.0.0/30"}, 2},
+		{"cidr2list_lookup_option_first", "", []string{"-f", "ip", "cidr2list", "10.0.0.0/30"}, 2},
+		{"cidr2range_ipv4", "", []string{"cidr2range", "10.1.2.3/14"}, 0},
+		{"cidr2range_ipv6", "", []string{"cidr2range", "2001:db8::1/48"}, 0},
+		{"cidr2range_invalid", "", []string{"cidr2range", "10.0.0.0/33"}, 1},
+		{"range2cidr_ipv4", "", []string{"range2cidr", "10.0.0.1", "10.0.1.6"}, 0},
+		{"range2cidr_ipv6", "", []string{"range2cidr", "2001:db8::1", "2001:db8::1:6"}, 0},
+		{"range2cidr_invalid", "", []string{"range2cidr", "10.0.0.1", "2001:db8::1"}, 1},
+		{"range2cidr_single", "", []string{"range2cidr", "10.0.0.1-10.0.1.6"}, 0},
+		{"range2cidr_mapped", "", []string{"range2cidr", "::-::ffff:255.255.255.255"}, 0},
+		{"range2cidr_reversed", "", []string{"range2cidr", "10.0.0.10", "10.0.0.1"}, 1},
+		{"range2cidr_invalid_ip", "", []string{"range2cidr", "10.0.0.1", "10.0.0.256"}, 1},
+		{"range2cidr_missing_end", "", []string{"range2cidr", "10.0.0.1"}, 1},
+		{"range2cidr_open_end", "", []string{"range2cidr", "1.2.3.4-"}, 1},
+		{"cidr2list_invalid", "", []string{"cidr2list", "foo"}, 1},
+		{"cidr2list_ipv4", "", []string{"cidr2list", "192.168.1.0/29"}, 0},
+		{"cidr2list_limit", "", []string{"cidr2list", "--offset", "256", "--limit", "3", "10.0.0.0/8"}, 0},
+		{"cidr2list_refused", "", []string{"cidr2list", "10.0.0.0/8"}, 1},
+		{"range2list_ipv6", "", []string{"range2list", "2001:db8::fffe", "2001:db8::1:2"}, 0},
+		{"range2list_single", "", []string{"range2list", "192.168.1.254-192.168.2.1"}, 0},
+		{"range2list_reversed", "", []string{"range2list", "2001:db8::2-2001:db8::1"}, 1},
+		{"splitcidr_ipv4", "", []string{"splitcidr", "10.0.0.0/22", "24"}, 0},
+		{"splitcidr_ipv6", "", []string{"splitcidr", "2001:db8::/32", "34"}, 0},
+		{"splitcidr_invalid", "", []string{"splitcidr", "10.0.0.0/22", "21"}, 1},
+		{"splitcidr_subnets", "", []string{"splitcidr", "--subnets", "6", "10.0.0.0/24"}, 0},
+		{"splitcidr_hosts", "", []string{"splitcidr", "10.0.0.0/24", "--hosts", "50"}, 0},
+		{"splitcidr_both", "", []string{"splitcidr", "10.0.0.0/24", "26", "--hosts", "50"}, 1},
+		{"splitcidr_refused", "", []string{"splitcidr", "2001:db8::/32", "64"}, 1},
+		{"splitcidr_hosts_refused", "", []string{"splitcidr", "--hosts", "1", "2001:db8::/32"}, 1},
+		{"splitcidr_limit", "", []string{"splitcidr", "--limit", "2", "2001:db8::/32", "64"}, 0},
+		{"freespace", "", []string{"freespace", "10.0.0.0/16", "@testdata/used.txt"}, 0},
+		{"freespace_size", "", []string{"freespace", "--size", "/23", "10.0.0.0/20", "10.0.0.0/24,10.0.4.0/22", "10.0.10.0/23"}, 0},
+		{"freespace_next", "testdata/used.txt", []string{"freespace", "--size", "24", "--next", "10.0.0.0/16", "-"}, 0},
+		{"freespace_none", "", []string{"freespace", "--size", "8", "--next", "10.0.0.0/16", "10.0.0.0/24"}, 1},
+		{"freespace_refused", "", []string{"freespace", "--size", "32", "0.0.0.0/0", "10.0.0.0/8"}, 1},
+		{"freespace_limit", "", []string{"freespace", "--size", "32", "--limit", "3", "0.0.0.0/0", "10.0.0.0/8"}, 0},
+		{"decode", "", []string{"decode", "2002:c000:204::1", "2001:0:4136:e378:8000:63bf:3fff:fdd2", "64:ff9b::8.8.8.8", "64:ff9b:1::c000:221", "::ffff:10.1.2.3", "2001:db8::1"}, 0},
+		{"decode_pretty", "", []string{"-o", "pretty", "decode", "2001:0:4136:e378:8000:63bf:3fff:fdd2", "fe80::1"}, 0},
+		{"decode_invalid", "", []string{"decode", "8.8.8.8"}, 1},
+		{"whois_ip", "", []string{"whois", "8.8.8.8"}, 0},
+		{"whois_asn_pretty", "", []string{"-o", "pretty", "whois", "AS15169", "2001:4860::8888"}, 0},
+		{"whois_not_found", "", []string{"whois", "192.0.2.1"}, 1},
+		{"whois_invalid", "", []string{"whois", "ASX"}, 1},
+		{"asn", "", []string{"asn", "--routes", "testdata/routes.txt", "AS15169"}, 0},
+		{"asn_geolocate", "", []string{"asn", "--routes", "testdata/routes.txt", "--geolocate", "13335"}, 0},
+		{"asn_geolocate_pretty", "", []string{"-o", "pretty", "asn", "--routes", "testdata/routes.txt", "--geolocate", "13335"}, 0},
+		{"asn_not_found", "", []string{"asn", "--routes", "testdata/routes.txt", "AS64496"}, 1},
+		{"asn_no_routes", "", []string{"asn", "15169"}, 1},
+		{"lookup_db", "", []string{"--db", "testdata/IP2LOCATION-DB26.BIN", "8.8.8.8", "2001:4860::8888", "192.0.2.1"}, 0},
+		{"lookup_db_filtered", "testdata/ips.txt", []string{"--db", "testdata/IP2LOCATION-DB26.BIN", "-f", "ip,country_code,city_name,asn", "--where", "asn == \"15169\"", "-"}, 0},
+		{"lookup_db_pretty", "", []string{"-o", "pretty", "--db", "testdata/IP2LOCATION-DB26.BIN", "1.1.1.1"}, 0},
+		{"lookup_db_missing", "", []string{"--db", "testdata/missing.BIN", "8.8.8.8"}, 1},
+		{"lookup_hybrid", "", []string{"--db", "testdata/IP2LOCATION-DB26.BIN", "--hybrid", "1.1.1.1"}, 0},
+		{"lookup_hybrid_filtered", "testdata/ips.txt", []string{"--db", "testdata/IP2LOCATION-DB26.BIN", "--hybrid", "-f", "ip,city_name,proxy.is_vpn,sources.city_name,sources.proxy", "--where", "!proxy.is_vpn", "-"}, 0},
+		{"lookup_hybrid_local_only", "", []string{"--db", "testdata/IP2LOCATION-DB26.BIN", "--hybrid", "-f", "ip,as,sources.*", "2001:4860::8888"}, 0},
+		{"lookup_hybrid_stale", "", []string{"--db", "testdata/IP2LOCATION-DB26.BIN", "--hybrid", "--max-age", "30", "-f", "ip,zip_code,sources.zip_code", "1.1.1.1"}, 0},
+		{"lookup_hybrid_api_error", "", []string{"--db", "testdata/IP2LOCATION-DB26.BIN", "--hybrid", "-f", "ip,country_code,proxy.is_vpn,sources.proxy", "192.0.2.1"}, 0},
+		{"lookup_hybrid_no_db", "", []string{"--hybrid", "8.8.8.8"}, 1},
+		{"lookup_db_no_ip", "", []string{"--db", "testdata/IP2LOCATION-DB26.BIN"}, 1},
+		{"randip_seed", "", []string{"randip", "-n", "5", "--within", "192.168.0.0/16", "--seed", "42"}, 0},
+		{"randip_ipv6", "", []string{"randip", "-n", "3", "-6", "--public-only", "--seed", "7"}, 0},
+		{"convert", "", []string{"convert", "8.8.8.8", "2001:db8::1"}, 0},
+		{"convert_pretty", "", []string{"-o", "pretty", "convert", "192.168.1.1"}, 0},
+		{"convert_to", "", []string{"convert", "--to", "decimal", "8.8.8.8", "::ffff:1.2.3.4", "0x08080808", "010.010.010.010", "0x7f.1"}, 0},
+		{"convert_reverse", "testdata/convert.txt", []string{"convert", "--to", "reverse", "-"}, 0},
+		{"convert_ipv6", "", []string{"convert", "-6", "--to", "ip", "1", "0x1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa."}, 0},
+		{"convert_invalid_format", "", []string{"convert", "--to", "octal", "2001:db8::1"}, 1},
+		{"randip_invalid", "", []string{"randip", "-6", "--within", "10.0.0.0/8"}, 1},
+		{"vlsm", "", []string{"vlsm", "10.0.0.0/24", "sales:50", "it:20", "wan:2"}, 0},
+		{"vlsm_pretty", "", []string{"-o", "pretty", "vlsm", "192.168.0.0/22", "office:200", "lab:100", "guest:60"}, 0},
+		{"vlsm_full", "", []string{"vlsm", "10.0.0.0/24", "a:200", "b:100"}, 1},
+		{"classify", "", []string{"classify", "10.1.2.3", "100.64.0.1", "2001:db8::1", "8.8.8.8", "::ffff:192.168.1.1"}, 0},
+		{"classify_pretty", "testdata/ips.txt", []string{"-o", "pretty", "classify", "-", "fe80::1", "2001::1", "2002:c000:204::1", "::ffff:10.0.0.1"}, 0},
+		{"classify_invalid", "", []string{"classify", "10.1.2"}, 1},
+		{"aggregate", "", []string{"aggregate", "10.0.0.0/25", "10.0.0.128/25", "2001:db8::/33", "2001:db8:8000::/33"}, 0},
+		{"aggregate_file", "testdata/allowlist.txt", []string{"aggregate", "@testdata/allowlist.txt", "-", "10.0.2.0/23"}, 0},
+		{"aggregate_stdin_twice", "testdata/allowlist.txt", []string{"aggregate", "-", "-"}, 1},
+		{"aggregate_invalid", "", []string{"aggregate", "10.0.0.10-10.0.0.1"}, 1},
+		{"cidr_union", "", []string{"cidr", "union", "10.0.0.0/25", "10.0.0.128/25,2001:db8::/32"}, 0},
+		{"cidr_intersect", "testdata/allowlist.txt", []string{"cidr", "intersect", "-", "10.0.0.0/16,2001:db8:1::/48"}, 0},
+		{"cidr_exclude", "", []string{"cidr", "exclude", "10.0.0.0/16", "@testdata/reserved.txt"}, 0},
+		{"cidr_invalid", "", []string{"cidr", "subtract", "10.0.0.0/16"}, 1},
+		{"help_subnet", "", []string{"help", "ipcalc"}, 0},
+		{"subnet", "", []string{"subnet", "192.168.1.10/24"}, 0},
+		{"subnet_pretty", "", []string{"-o", "pretty", "ipcalc", "192.168.1.10", "255.255.255.0"}, 0},
+		{"subnet_ipv6_pretty", "", []string{"subnet", "-o", "pretty", "2001:db8::1/48"}, 0},
+		{"subnet_invalid", "", []string{"subnet", "192.168.1.10", "255.0.255.0"}, 1},
+		{"contains", "", []string{"contains", "10.0.0.0/8,10.1.0.0/16,2001:db8::/32", "10.1.2.3", "10.2.0.0/16", "8.8.8.8"}, 0},
+		{"contains_stdin", "testdata/queries.txt", []string{"contains", "@testdata/allowlist.txt", "-"}, 0},
+		{"contains_invert", "testdata/queries.txt", []string{"contains", "--invert", "@testdata/allowlist.txt", "-"}, 0},
+		{"contains_not_found", "", []string{"contains", "10.0.0.0/8", "8.8.8.8"}, 1},
+		{"contains_invalid", "", []string{"contains", "10.0.0.0/8", "10.0.0.1-10.0.0.9"}, 2},
+		{"contains_stdin_twice", "testdata/queries.txt", []string{"contains", "-", "-"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, code := runCLI(t, tt.stdin, tt.args...)
+			got = strings.ReplaceAll(got, serverURL, "http://localhost")
+
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d", code, tt.code)
+			}
+
+			golden := filepath.Join("testdata", "golden", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+func TestRandIPCommand(t *testing.T) {
+	got, code := runCLI(t, "", "randip")
+
+	if code != 0 || !IsIPv4(got[:len(got)-1]) {
+		t.Errorf("randip = %q, %d", got, code)
+	}
+}
+
+func TestRDNSCommand(t *testing.T) {
+	resolver := startDNSServer(t)
+
+	got, code := runCLI(t, "", "--rdns", "--resolver", resolver, "8.8.8.8")
+	if code != 0 || !strings.HasSuffix(got, `,"rdns":{"names":["dns.google"],"forward_confirmed":true}}`+"\n") {
+		t.Errorf("--rdns = %q, %d", got, code)
+	}
+
+	got, code = runCLI(t, "", "--rdns", "--resolver", resolver, "-f", "ip,rdns.names[0] as ptr,rdns.forward_confirmed", "8.8.8.8")
+	if want := "ip,ptr,rdns.forward_confirmed\n\"8.8.8.8\",\"dns.google\",true\n"; code != 0 || got != want {
+		t.Errorf("--rdns -f = %q, %d, want %q", got, code, want)
+	}
+}
+
+func TestConfigCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+
+	if _, code := runCLI(t, "", "config", "TESTKEY"); code != 0 {
+		t.Fatalf("config exit code = %d", code)
+	}
+
+	path, err := ConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byteValue, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(byteValue) != `{"api_key":"TESTKEY"}` {
+		t.Errorf("config file = %s", byteValue)
+	}
+}
+
+func TestSuggestCommand(t *testing.T) {
+	tests := map[string]string{
+		"splitcdr": "splitcidr",
+		"cidr2rng": "cidr2range",
+		"rand":     "randip",
+		"chek":     "check",
+		"xyz":      "",
+	}
+
+	for name, want := range tests {
+		if got := SuggestCommand(name); got != want {
+			t.Errorf("SuggestCommand(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "")
+
+	got, err := parseArgs(fs, []string{"a", "--limit", "5", "b", "--", "-c"})
+	if err != nil || *limit != 5 || !reflect.DeepEqual(got, []string{"a", "b", "-c"}) {
+		t.Errorf("parseArgs = %v, %v, limit %d", got, err, *limit)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const completionName string = "ip2locationio"
+
+// The completionCommand struct stores the name, summary and options of a command for the completion scripts.
+type completionCommand struct {
+	Name        string
+	Description string
+	Flags       []completionFlag
+}
+
+// The completionFlag struct stores a command option for the completion scripts.
+type completionFlag struct {
+	Name        string
+	Description string
+	TakesValue  bool
+	File        bool
+	Values      string
+}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// The completionData struct stores the values embedded into the completion scripts.
+type completionData struct {
+	Name         string
+	Commands     []completionCommand
+	Languages    string
+	Formats      string
+	Fields       string
+	Plans        string
+	Shells       string
+	CIDROps      string
+	SpecialModes string
+	FileFlags    string
+}
+
+// the values completed for the options which take one of a list of values.
+var completionValues = map[string][]string{
+	"-o":        outputFormats,
+	"-l":        languages,
+	"--special": specialModes,
+}
+
+const bashCompletion = `# bash completion for {{.Name}}
+# Usage: source <({{.Name}} completion bash)
+
+_{{.Name}}() {
+    local cur prev
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+        -o)
+            COMPREPLY=( $(compgen -W "{{.Formats}}" -- "$cur") )
+            return
+            ;;
+        -l)
+            COMPREPLY=( $(compgen -W "{{.Languages}}" -- "$cur") )
+            return
+            ;;
+        -f)
+            local fields="{{.Fields}}"
+            if [[ "$cur" == *,* ]]; then
+                COMPREPLY=( $(compgen -P "${cur%,*}," -W "$fields" -- "${cur##*,}") )
+            else
+                COMPREPLY=( $(compgen -W "$fields" -- "$cur") )
+            fi
+            compopt -o nospace 2>/dev/null
+            return
+            ;;
+        -k|-where|--where|-resolver|--resolver|-max-age|--max-age)
+            return
+            ;;
+        -special|--special)
+            COMPREPLY=( $(compgen -W "{{.SpecialModes}}" -- "$cur") )
+            return
+            ;;
+        {{.FileFlags}})
+            COMPREPLY=( $(compgen -f -- "$cur") )
+            return
+            ;;
+        completion)
+            COMPREPLY=( $(compgen -W "{{.Shells}}" -- "$cur") )
+            return
+            ;;
+        fields)
+            COMPREPLY=( $(compgen -W "{{.Plans}}" -- "$cur") )
+            return
+            ;;
+        cidr)
+            COMPREPLY=( $(compgen -W "{{.CIDROps}}" -- "$cur") )
+            return
+            ;;
+        check)
+            COMPREPLY=( $(compgen -f -- "$cur") )
+            return
+            ;;
+        help)
+            COMPREPLY=( $(compgen -W "{{range .Commands}}{{.Name}} {{end}}" -- "$cur") )
+            return
+            ;;
+    esac
+
+    if [[ "$cur" == -* ]]; then
+        local flags="-v -h -k -l -o -f --where --special --embedded --rdns --resolver --db --hybrid --max-age"
+        local word
+        for word in "${COMP_WORDS[@]:1:COMP_CWORD-1}"; do
+            case "$word" in
+{{- range .Commands}}
+                {{.Name}})
+                    flags="-h{{range .Flags}} {{.Name}}{{end}}"
+                    ;;
+{{- end}}
+            esac
+        done
+        COMPREPLY=( $(compgen -W "$flags" -- "$cur") )
+    else
+        COMPREPLY=( $(compgen -W "{{range .Commands}}{{.Name}} {{end}}" -- "$cur") )
+    fi
+}
+
+complete -F _{{.Name}} {{.Name}}
+`
+
+const zshCompletion = `#compdef {{.Name}}
+# zsh completion for {{.Name}}
+# Usage: source <({{.Name}} completion zsh)
+
+_{{.Name}}_fields() {
+    _values -s , 'field' {{.Fields}}
+}
+
+_{{.Name}}() {
+    local -a commands
+    commands=(
+{{- range .Commands}}
+        '{{.Name}}:{{quote .Description}}'
+{{- end}}
+    )
+
+    _arguments -s \
+        '-v[Show version]' \
+        '-h[Print help]' \
+        '-k[API key]:api key:' \
+        '-l[Translation language]:language:({{.Languages}})' \
+        '-o[Output format]:format:({{.Formats}})' \
+        '-f[Filter fields]:fields:_{{.Name}}_fields' \
+        '--where[Only output the results matching the expression]:expression:' \
+        '--special[Skip or annotate the special-purpose addresses]:mode:({{.SpecialModes}})' \
+        '--embedded[Look up the IPv4 address embedded in IPv6 addresses]' \
+        '--rdns[Add the reverse DNS names]' \
+        '--resolver[DNS server address for --rdns]:address:' \
+        '--db[IP2Location BIN database file]:file:_files' \
+        '--hybrid[Use the API for the fields not in the database]' \
+        '--max-age[Use the API when the database is older than the days]:days:' \
+        '1: :->command' \
+        '*:: :->args'
+
+    case $state in
+        command)
+            _describe 'command' commands
+            ;;
+        args)
+            case $words[1] in
+{{- range .Commands}}
+                {{.Name}})
+                    _arguments '-h[Print help]'
+{{- range .Flags}} '{{.Name}}[{{quote (zshEscape .Description)}}]
+{{- if .Values}}:value:({{.Values}})
+{{- else if eq .Name "-f"}}:fields:_{{$.Name}}_fields
+{{- else if .File}}:file:_files
+{{- else if .TakesValue}}:value:{{end}}'{{end}}
+{{- if eq .Name "completion"}} '1:shell:({{$.Shells}})'
+{{- else if eq .Name "fields"}} '1:plan:({{$.Plans}})'
+{{- else if eq .Name "cidr"}} '1:operation:({{$.CIDROps}})' '*:list:_files'
+{{- else if eq .Name "check"}} '*:file:_files'
+{{- else if eq .Name "help"}} '1:command:({{range $.Commands}}{{.Name}} {{end}})'
+{{- else}} '*: :'{{end}}
+                    ;;
+{{- end}}
+            esac
+            ;;
+    esac
+}
+
+if [ "$funcstack[1]" = "_{{.Name}}" ]; then
+    _{{.Name}} "$@"
+else
+    compdef _{{.Name}} {{.Name}}
+fi
+`
+
+const fishCompletion = `# fish completion for {{.Name}}
+# Usage: {{.Name}} completion fish | source
+
+function __{{.Name}}_fields
+    set -l prefix (string replace -r '[^,]*$' '' -- (commandline -ct))
+    for field in {{.Fields}}
+        echo $prefix$field
+    end
+end
+
+complete -c {{.Name}} -f
+complete -c {{.Name}} -n '__fish_use_subcommand' -s v -d 'Show version'
+complete -c {{.Name}} -n '__fish_use_subcommand' -s h -d 'Print help'
+complete -c {{.Name}} -n '__fish_use_subcommand' -s k -x -d 'API key'
+complete -c {{.Name}} -n '__fish_use_subcommand' -s l -x -a '{{.Languages}}' -d 'Translation language'
+complete -c {{.Name}} -n '__fish_use_subcommand' -s o -x -a '{{.Formats}}' -d 'Output format'
+complete -c {{.Name}} -n '__fish_use_subcommand' -s f -x -a '(__{{.Name}}_fields)' -d 'Filter fields'
+complete -c {{.Name}} -n '__fish_use_subcommand' -l where -x -d 'Only output the results matching the expression'
+complete -c {{.Name}} -n '__fish_use_subcommand' -l special -x -a '{{.SpecialModes}}' -d 'Skip or annotate the special-purpose addresses'
+complete -c {{.Name}} -n '__fish_use_subcommand' -l embedded -d 'Look up the IPv4 address embedded in IPv6 addresses'
+complete -c {{.Name}} -n '__fish_use_subcommand' -l rdns -d 'Add the reverse DNS names'
+complete -c {{.Name}} -n '__fish_use_subcommand' -l resolver -x -d 'DNS server address for --rdns'
+complete -c {{.Name}} -n '__fish_use_subcommand' -l db -r -F -d 'IP2Location BIN database file'
+complete -c {{.Name}} -n '__fish_use_subcommand' -l hybrid -d 'Use the API for the fields not in the database'
+complete -c {{.Name}} -n '__fish_use_subcommand' -l max-age -x -d 'Use the API when the database is older than the days'
+{{- range .Commands}}
+complete -c {{$.Name}} -n '__fish_use_subcommand' -a '{{.Name}}' -d '{{quote .Description}}'
+{{- end}}
+complete -c {{.Name}} -n '__fish_seen_subcommand_from completion' -a '{{.Shells}}'
+complete -c {{.Name}} -n '__fish_seen_subcommand_from fields' -a '{{.Plans}}'
+complete -c {{.Name}} -n '__fish_seen_subcommand_from cidr; and not __fish_seen_subcommand_from {{.CIDROps}}' -a '{{.CIDROps}}'
+complete -c {{.Name}} -n '__fish_seen_subcommand_from check' -F
+complete -c {{.Name}} -n '__fish_seen_subcommand_from help' -a '{{range .Commands}}{{.Name}} {{end}}'
+{{- range $c := .Commands}}
+complete -c {{$.Name}} -n '__fish_seen_subcommand_from {{$c.Name}}' -s h -d 'Print help'
+{{- range .Flags}}
+complete -c {{$.Name}} -n '__fish_seen_subcommand_from {{$c.Name}}' {{if eq (len .Name) 2}}-s{{else}}-l{{end}} {{trimDash .Name}}
+{{- if .Values}} -x -a '{{.Values}}'
+{{- else if eq .Name "-f"}} -x -a '(__{{$.Name}}_fields)'
+{{- else if .File}} -r -F
+{{- else if .TakesValue}} -x{{end}} -d '{{quote .Description}}'
+{{- end}}{{end}}
+`
+
+// Completion returns the completion script for the shell.
+func Completion(shell string) (string, error) {
+	var tpl string
+	var quote func(string) string
+
+	// the descriptions are written in single quotes
+	switch shell {
+	case "bash":
+		tpl = bashCompletion
+		quote = strings.NewReplacer("'", `'\''`).Replace
+	case "zsh":
+		tpl = zshCompletion
+		quote = strings.NewReplacer("'", `'\''`).Replace
+	case "fish":
+		tpl = fishCompletion
+		quote = strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace
+	default:
+		return "", errors.New("Invalid shell. Valid values: " + strings.Join(completionShells, " | "))
+	}
+
+	commands := completionCommands()
+	data := completionData{
+		Name:         completionName,
+		Commands:     commands,
+		Languages:    strings.Join(languages, " "),
+		Formats:      strings.Join(outputFormats, " "),
+		Fields:       strings.Join(FieldPaths(), " "),
+		Plans:        strings.Join(plans, " "),
+		Shells:       strings.Join(completionShells, " "),
+		CIDROps:      strings.Join(cidrOperations, " "),
+		SpecialModes: strings.Join(specialModes, " "),
+		FileFlags:    strings.Join(completionFileFlags(commands), "|"),
+	}
+
+	var sb strings.Builder
+	funcs := template.FuncMap{
+		"trimDash": func(s string) string { return strings.TrimLeft(s, "-") },
+		"quote":    quote,
+		// the brackets and colons end the description of a zsh option
+		"zshEscape": strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace,
+	}
+	t := template.Must(template.New(shell).Funcs(funcs).Parse(tpl))
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// returns the commands and their options from the command registry.
+func completionCommands() []completionCommand {
+	var res []completionCommand
+
+	for _, c := range commands {
+		cc := completionCommand{Name: c.Name, Description: c.Summary}
+		c.flagSet().VisitAll(func(f *flag.Flag) {
+			takesValue := true
+			if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+				takesValue = false
+			}
+			valueName, usage := flag.UnquoteUsage(f)
+			name := "--" + f.Name
+			if len(f.Name) == 1 {
+				name = "-" + f.Name
+			}
+			values := strings.Join(completionValues[name], " ")
+			cc.Flags = append(cc.Flags, completionFlag{name, usage, takesValue, valueName == "FILE", values})
+		})
+		res = append(res, cc)
+
+		for _, alias := range c.Aliases {
+			ac := cc
+			ac.Name = alias
+			res = append(res, ac)
+		}
+	}
+
+	return res
+}
+
+// returns the options reading a file, with both one and two dashes as the flag package accepts.
+func completionFileFlags(commands []completionCommand) []string {
+	var res []string
+	seen := map[string]bool{}
+
+	for _, c := range commands {
+		for _, f := range c.Flags {
+			name := strings.TrimLeft(f.Name, "-")
+			if f.File && !seen[name] {
+				seen[name] = true
+				res = append(res, "-"+name, "--"+name)
+			}
+		}
+	}
+
+	return res
+}
+
+func PrintCompletion(shell string) int {
+	res, err := Completion(shell)
+
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	fmt.Print(res)
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range completionShells {
+		script, err := Completion(shell)
+		if err != nil || !strings.Contains(script, "splitcidr") || !strings.Contains(script, "proxy.is_vpn") {
+			t.Errorf("Completion(%q) = %v, want the commands and fields", shell, err)
+		}
+	}
+
+	// the apostrophes are escaped for the shell instead of removed
+	quoted := map[string]string{
+		"zsh":  `registry'\''s`,
+		"fish": `registry\'s`,
+	}
+	for shell, want := range quoted {
+		if script, _ := Completion(shell); !strings.Contains(script, want) {
+			t.Errorf("Completion(%q) does not contain %s", shell, want)
+		}
+	}
+
+	// the options shared with the top level are completed after the lookup command
+	if script, _ := Completion("zsh"); !strings.Contains(script, "'-k[API key\\: Get your API key") {
+		t.Error(`Completion("zsh") does not complete -k after the lookup command`)
+	}
+
+	if _, err := Completion("tcsh"); err == nil {
+		t.Error(`Completion("tcsh") error = nil, want error`)
+	}
+}
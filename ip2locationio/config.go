@@ -34,38 +34,28 @@ func LoadConfig() {
 	config.APIKey = ""
 }
 
-func UpdateAPIKey(apiKey string) {
+func UpdateAPIKey(apiKey string) int {
 	config.APIKey = apiKey
-	SaveConfig()
+
+	if err := SaveConfig(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
 }
 
-func SaveConfig() {
+func SaveConfig() error {
 	path, err := ConfigPath()
-
 	if err != nil {
-		// fmt.Println(err)
-	} else {
-		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0700)
-		if err != nil {
-			// fmt.Println(err)
-		} else {
-			byteValue, err := json.Marshal(&config)
-
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				_, err = file.Write(byteValue)
-
-				if err != nil {
-					fmt.Println(err)
-				}
-			}
-		}
+		return err
+	}
 
-		if err := file.Close(); err != nil {
-			// fmt.Println(err)
-		}
+	byteValue, err := json.Marshal(&config)
+	if err != nil {
+		return err
 	}
+
+	return ioutil.WriteFile(path, byteValue, 0700)
 }
 
 func ConfigPath() (string, error) {
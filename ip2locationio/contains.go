@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// NewPrefixTrie returns a trie of the CIDRs, ranges and IP addresses in the list.
+func NewPrefixTrie(entries []string) (*PrefixTrie, error) {
+	t := &PrefixTrie{}
+
+	for _, entry := range entries {
+		r, err := ParseIPRange(entry)
+		if err != nil {
+			return nil, err
+		}
+		t.Insert(r, entry)
+	}
+
+	return t, nil
+}
+
+// ParseIPOrCIDR returns the range for an IP address or a CIDR.
+func ParseIPOrCIDR(str string) (ipRange, error) {
+	r, err := ParseIPRange(str)
+	if err != nil || strings.Contains(str, "-") {
+		return ipRange{}, errors.New("Not a valid IP address or CIDR: " + str)
+	}
+	return r, nil
+}
+
+// Contains returns the entry of the most specific prefix in the trie containing the IP address or CIDR,
+// or false if there is none.
+func Contains(t *PrefixTrie, query string) (string, bool, error) {
+	r, err := ParseIPOrCIDR(query)
+	if err != nil {
+		return "", false, err
+	}
+
+	entry, ok := t.Lookup(r)
+	return entry, ok, nil
+}
+
+func PrintContains(args []string) int {
+	if err := checkStdin(args); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	list, err := ReadList(arg(args, 0))
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	} else if len(list) == 0 {
+		fmt.Println("No CIDR, range or IP address supplied.")
+		return 2
+	}
+
+	t, err := NewPrefixTrie(list)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+
+	queries := args[1:]
+	if len(queries) == 0 {
+		fmt.Println("No IP address or CIDR supplied.")
+		return 2
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	found := false
+	for _, query := range queries {
+		var matched bool
+		if query == "-" {
+			matched, err = containsStream(w, t, os.Stdin)
+		} else {
+			matched, err = containsQuery(w, t, query)
+		}
+		if err != nil {
+			w.Flush()
+			fmt.Println(err)
+			return 2
+		}
+		found = found || matched
+	}
+
+	if !found {
+		return 1
+	}
+	return 0
+}
+
+// writes the query and the matching entry, or only the query when inverted,
+// and returns whether it was written.
+func containsQuery(w io.Writer, t *PrefixTrie, query string) (bool, error) {
+	entry, ok, err := Contains(t, query)
+	if err != nil {
+		return false, err
+	}
+
+	if containsInvert {
+		if !ok {
+			fmt.Fprintln(w, query)
+		}
+		return !ok, nil
+	}
+
+	if ok {
+		fmt.Fprintf(w, "%s,%s\n", query, entry)
+	}
+	return ok, nil
+}
+
+// checks one query per line from the reader as it is read, reporting the invalid lines
+// on the standard error, and returns whether any query was written.
+func containsStream(w io.Writer, t *PrefixTrie, r io.Reader) (bool, error) {
+	found := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		query := stripComment(scanner.Text())
+		if query == "" {
+			continue
+		}
+
+		matched, err := containsQuery(w, t, query)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		found = found || matched
+	}
+
+	return found, scanner.Err()
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var convertFormats = []string{"ip", "decimal", "hex", "binary", "octal", "expanded", "compressed", "reverse", "mapped"}
+
+// The ConvertedIP struct stores the IP address written in the supported formats.
+type ConvertedIP struct {
+	IP         string      `json:"ip"`
+	Version    int         `json:"version"`
+	Decimal    json.Number `json:"decimal"`
+	Hex        string      `json:"hex"`
+	Binary     string      `json:"binary"`
+	Octal      string      `json:"octal,omitempty"`
+	Expanded   string      `json:"expanded,omitempty"`
+	Compressed string      `json:"compressed,omitempty"`
+	Reverse    string      `json:"reverse"`
+	IPv4Mapped string      `json:"ipv4_mapped,omitempty"`
+}
+
+// ParseIPValue returns the IP address written in any of the supported formats, where the decimal,
+// hex and binary numbers are IPv6 if ipv6 is true or they are too long for IPv4.
+func ParseIPValue(value string, ipv6 bool) (netip.Addr, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	invalid := errors.New("Not a valid IP address or number: " + value)
+
+	var u uint128
+	var err error
+
+	switch {
+	case strings.HasSuffix(strings.TrimSuffix(s, "."), ".in-addr.arpa"):
+		return parseReverse4(strings.TrimSuffix(strings.TrimSuffix(s, "."), ".in-addr.arpa"), invalid)
+	case strings.HasSuffix(strings.TrimSuffix(s, "."), ".ip6.arpa"):
+		return parseReverse6(strings.TrimSuffix(strings.TrimSuffix(s, "."), ".ip6.arpa"), invalid)
+	case strings.HasPrefix(s, "0x") && !strings.Contains(s, "."):
+		u, err = parseUint128(s[2:], 16, 4)
+		ipv6 = ipv6 || len(s)-2 > 8
+	case strings.HasPrefix(s, "0b"):
+		u, err = parseUint128(s[2:], 2, 1)
+		ipv6 = ipv6 || len(s)-2 > 32
+	case s != "" && strings.Trim(s, "0123456789") == "":
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return netip.Addr{}, invalid
+		}
+
+		var ip string
+		if ipv6 || n.Cmp(maxIPv4Range) > 0 {
+			ip, err = DecimalToIPv6(n)
+		} else {
+			ip, err = DecimalToIPv4(n)
+		}
+		if err != nil {
+			return netip.Addr{}, invalid
+		}
+		return netip.MustParseAddr(ip), nil
+	default:
+		if IsIPv4(s) || IsIPv6(s) {
+			return parseAddr(s)
+		}
+		return parseInetAton(s, invalid)
+	}
+
+	if err != nil || (!ipv6 && u.Hi|u.Lo>>32 != 0) {
+		return netip.Addr{}, invalid
+	}
+	return u.Addr(ipv6), nil
+}
+
+// returns the number written with up to 128 bits in the base, where a digit has the number of bits.
+func parseUint128(s string, base int, digitBits int) (uint128, error) {
+	digits := 128 / digitBits
+	if s == "" || len(s) > digits {
+		return uint128{}, errors.New("Invalid number.")
+	}
+
+	s = strings.Repeat("0", digits-len(s)) + s
+	hi, err := strconv.ParseUint(s[:digits/2], base, 64)
+	if err != nil {
+		return uint128{}, err
+	}
+	lo, err := strconv.ParseUint(s[digits/2:], base, 64)
+	if err != nil {
+		return uint128{}, err
+	}
+	return uint128{hi, lo}, nil
+}
+
+// returns the IPv4 address written with 2 to 4 parts as inet_aton reads them, where the parts
+// starting with 0x are hex, the other parts starting with 0 octal and the rest decimal, and the
+// last part fills the remaining bytes.
+func parseInetAton(s string, invalid error) (netip.Addr, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 4 {
+		return netip.Addr{}, invalid
+	}
+
+	var n uint32
+	for i, part := range parts {
+		bits := 8
+		if i == len(parts)-1 {
+			bits = 8 * (5 - len(parts))
+		}
+
+		base := 10
+		if strings.HasPrefix(part, "0x") {
+			base = 16
+			part = part[2:]
+		} else if len(part) > 1 && part[0] == '0' {
+			base = 8
+		}
+
+		v, err := strconv.ParseUint(part, base, bits)
+		if err != nil {
+			return netip.Addr{}, invalid
+		}
+		n = n<<bits | uint32(v)
+	}
+
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], n)
+	return netip.AddrFrom4(b), nil
+}
+
+// returns the IPv4 address for the labels of an in-addr.arpa name.
+func parseReverse4(s string, invalid error) (netip.Addr, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 4 {
+		return netip.Addr{}, invalid
+	}
+
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+
+	addr, err := netip.ParseAddr(strings.Join(parts, "."))
+	if err != nil {
+		return netip.Addr{}, invalid
+	}
+	return addr, nil
+}
+
+// returns the IPv6 address for the nibbles of an ip6.arpa name.
+func parseReverse6(s string, invalid error) (netip.Addr, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 32 {
+		return netip.Addr{}, invalid
+	}
+
+	var buf strings.Builder
+	for i := len(parts) - 1; i >= 0; i-- {
+		if len(parts[i]) != 1 {
+			return netip.Addr{}, invalid
+		}
+		buf.WriteString(parts[i])
+	}
+
+	u, err := parseUint128(buf.String(), 16, 4)
+	if err != nil {
+		return netip.Addr{}, invalid
+	}
+	return u.Addr(true), nil
+}
+
+// Convert returns the IP address written in any of the supported formats in all the formats.
+func Convert(value string, ipv6 bool) (ConvertedIP, error) {
+	addr, err := ParseIPValue(value, ipv6)
+	if err != nil {
+		return ConvertedIP{}, err
+	}
+
+	u := uint128FromAddr(addr)
+	c := ConvertedIP{
+		IP:      addr.String(),
+		Version: 6,
+		Decimal: json.Number(u.BigInt().String()),
+		Reverse: reverseName(addr),
+	}
+
+	if addr.Is6() {
+		c.Hex = fmt.Sprintf("0x%016x%016x", u.Hi, u.Lo)
+		c.Binary = fmt.Sprintf("0b%064b%064b", u.Hi, u.Lo)
+		c.Expanded = expandAddr(addr)
+		c.Compressed = addr.String()
+		return c, nil
+	}
+
+	b := addr.As4()
+	c.Version = 4
+	c.Hex = fmt.Sprintf("0x%08x", u.Lo)
+	c.Binary = fmt.Sprintf("0b%032b", u.Lo)
+	c.Octal = octalByte(b[0]) + "." + octalByte(b[1]) + "." + octalByte(b[2]) + "." + octalByte(b[3])
+	c.IPv4Mapped = netip.AddrFrom16(addr.As16()).String()
+	return c, nil
+}
+
+// returns the byte as an octal part starting with 0, or 0 for a zero byte.
+func octalByte(b byte) string {
+	if b == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("0%o", b)
+}
+
+// returns the in-addr.arpa or ip6.arpa name for the reverse DNS lookup of the IP address.
+func reverseName(addr netip.Addr) string {
+	if addr.Is4() {
+		b := addr.As4()
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", b[3], b[2], b[1], b[0])
+	}
+
+	b := addr.As16()
+	var buf strings.Builder
+	for i := len(b) - 1; i >= 0; i-- {
+		fmt.Fprintf(&buf, "%x.%x.", b[i]&0xf, b[i]>>4)
+	}
+	buf.WriteString("ip6.arpa")
+	return buf.String()
+}
+
+// returns an error if the format is not empty or supported.
+func checkConvertFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, f := range convertFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return errors.New("Invalid format. Valid values: " + strings.Join(convertFormats, " | "))
+}
+
+// returns the converted IP address in the format.
+func convertedValue(c ConvertedIP, format string) (string, error) {
+	values := map[string]string{
+		"ip":         c.IP,
+		"decimal":    c.Decimal.String(),
+		"hex":        c.Hex,
+		"binary":     c.Binary,
+		"octal":      c.Octal,
+		"expanded":   c.Expanded,
+		"compressed": c.Compressed,
+		"reverse":    c.Reverse,
+		"mapped":     c.IPv4Mapped,
+	}
+
+	if values[format] != "" {
+		return values[format], nil
+	} else if c.Version == 4 {
+		return "", errors.New("The " + format + " format is only for IPv6 addresses: " + c.IP)
+	}
+	return "", errors.New("The " + format + " format is only for IPv4 addresses: " + c.IP)
+}
+
+func PrintConvert(args []string) int {
+	convertTo = strings.ToLower(convertTo)
+	if err := checkConvertFormat(convertTo); err != nil {
+		fmt.Println(err)
+		return 1
+	} else if len(args) == 0 {
+		fmt.Println("No IP address or number supplied.")
+		return 1
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	for _, value := range args {
+		var err error
+		if value == "-" {
+			err = convertStream(w, os.Stdin)
+		} else {
+			err = convertValue(w, value)
+		}
+		if err != nil {
+			w.Flush()
+			fmt.Println(err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// writes the IP address in the --to format, or in all the formats.
+func convertValue(w io.Writer, value string) error {
+	c, err := Convert(value, convertIPv6)
+	if err != nil {
+		return err
+	}
+
+	if convertTo != "" {
+		res, err := convertedValue(c, convertTo)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, res)
+		return nil
+	}
+
+	if outputFormat == "json" {
+		byteValue, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n", byteValue)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "IP:\t%s\n", c.IP)
+	fmt.Fprintf(tw, "Version:\t%d\n", c.Version)
+	fmt.Fprintf(tw, "Decimal:\t%s\n", c.Decimal)
+	fmt.Fprintf(tw, "Hex:\t%s\n", c.Hex)
+	fmt.Fprintf(tw, "Binary:\t%s\n", c.Binary)
+	if c.Octal != "" {
+		fmt.Fprintf(tw, "Octal:\t%s\n", c.Octal)
+	}
+	if c.Expanded != "" {
+		fmt.Fprintf(tw, "Expanded:\t%s\n", c.Expanded)
+		fmt.Fprintf(tw, "Compressed:\t%s\n", c.Compressed)
+	}
+	fmt.Fprintf(tw, "Reverse:\t%s\n", c.Reverse)
+	if c.IPv4Mapped != "" {
+		fmt.Fprintf(tw, "IPv4-mapped:\t%s\n", c.IPv4Mapped)
+	}
+	return tw.Flush()
+}
+
+// converts one value per line from the reader as it is read, reporting the invalid lines
+// on the standard error.
+func convertStream(w io.Writer, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		value := stripComment(scanner.Text())
+		if value == "" {
+			continue
+		}
+
+		if err := convertValue(w, value); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseIPValue(t *testing.T) {
+	tests := []struct {
+		value string
+		ipv6  bool
+		want  string
+	}{
+		{"8.8.8.8", false, "8.8.8.8"},
+		{"::ffff:8.8.8.8", false, "8.8.8.8"},
+		{"2001:0db8:0000:0000:0000:0000:0000:0001", false, "2001:db8::1"},
+		{"134744072", false, "8.8.8.8"},
+		{"4294967295", false, "255.255.255.255"},
+		{"4294967296", false, "::1:0:0"},
+		{"1", true, "::1"},
+		{"0x08080808", false, "8.8.8.8"},
+		{"0X8080808", false, "8.8.8.8"},
+		{"0x000000001", false, "::1"},
+		{"0x20010db8000000000000000000000001", false, "2001:db8::1"},
+		{"0b00001000000010000000100000001000", false, "8.8.8.8"},
+		{"0b1", true, "::1"},
+		{"010.010.010.010", false, "8.8.8.8"},
+		{"0377.0.0.01", false, "255.0.0.1"},
+		{"10.0.0.01", false, "10.0.0.1"},
+		{"010.0.0.255", false, "8.0.0.255"},
+		{"00.00.00.00", false, "0.0.0.0"},
+		{"0x7f.1", false, "127.0.0.1"},
+		{"0x7F.0.0.0x01", false, "127.0.0.1"},
+		{"10.1", false, "10.0.0.1"},
+		{"192.168.257", false, "192.168.1.1"},
+		{"10.0xffffff", false, "10.255.255.255"},
+		{"8.8.8.8.in-addr.arpa", false, "8.8.8.8"},
+		{"4.3.2.1.IN-ADDR.ARPA.", false, "1.2.3.4"},
+		{"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", false, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseIPValue(tt.value, tt.ipv6)
+		if err != nil || got.String() != tt.want {
+			t.Errorf("ParseIPValue(%q, %t) = %v, %v, want %s", tt.value, tt.ipv6, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "x", "0x", "0x123456789012345678901234567890123", "0b2", "340282366920938463463374607431768211456", "08.8.8.8", "0400.0.0.0", "300.1.1.1", "256.1.1.1", "10.0.0.256", "1.2.3.-4", "1.2.3.+4", "1..2.3", "1.0x", "0x100.1", "1.16777216", "1.2.65536", "1.2.3.4.5", "1.2.3.in-addr.arpa", "256.1.1.1.in-addr.arpa", "1.2.ip6.arpa", "fe80::1%eth0"} {
+		if got, err := ParseIPValue(value, false); err == nil {
+			t.Errorf("ParseIPValue(%q) = %v, want error", value, got)
+		}
+	}
+}
+
+func TestConvertOctal(t *testing.T) {
+	tests := map[string]string{
+		"0.0.0.0":         "0.0.0.0",
+		"10.0.0.1":        "012.0.0.01",
+		"255.255.255.255": "0377.0377.0377.0377",
+	}
+
+	for ip, want := range tests {
+		c, err := Convert(ip, false)
+		if err != nil || c.Octal != want {
+			t.Errorf("Convert(%q).Octal = %q, %v, want %q", ip, c.Octal, err, want)
+		}
+	}
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	for _, ip := range []string{"0.0.0.0", "8.8.8.8", "255.255.255.255", "::", "::1", "2001:db8::ff00:42:8329", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		c, err := Convert(ip, false)
+		if err != nil {
+			t.Fatalf("Convert(%q) error = %v", ip, err)
+		}
+
+		for _, format := range convertFormats {
+			value, err := convertedValue(c, format)
+			if err != nil {
+				continue
+			}
+
+			got, err := Convert(value, c.Version == 6)
+			if err != nil || got != c {
+				t.Errorf("Convert(%q) from %s %q = %+v, %v, want %+v", ip, format, value, got, err, c)
+			}
+		}
+	}
+}
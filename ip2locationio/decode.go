@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+// The EmbeddedIPv4 struct stores the IPv4 address embedded in an IPv6 address by a transition mechanism.
+type EmbeddedIPv4 struct {
+	IP           string `json:"ip"`
+	Mechanism    string `json:"mechanism"`
+	IPv4         string `json:"ipv4,omitempty"`
+	TeredoServer string `json:"teredo_server,omitempty"`
+	TeredoPort   uint16 `json:"teredo_port,omitempty"`
+}
+
+// DecodeIPv6 returns the IPv4 address embedded in the 6to4, Teredo, NAT64 or IPv4-mapped IPv6 address,
+// or the mechanism "none" if there is none. For Teredo, the IPv4 address and port are the client's.
+func DecodeIPv6(ip string) (EmbeddedIPv4, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Zone() != "" || addr.Is4() {
+		return EmbeddedIPv4{}, errors.New("Not a valid IPv6 address.")
+	}
+
+	res := EmbeddedIPv4{IP: ip, Mechanism: "none"}
+	s, ok := classifyAddr(addr)
+	if !ok {
+		return res, nil
+	}
+
+	b := addr.As16()
+	var v4 [4]byte
+
+	switch s.CIDR {
+	case "2002::/16":
+		copy(v4[:], b[2:6])
+	case "2001::/32":
+		// the client address and port are obfuscated by inverting the bits
+		binary.BigEndian.PutUint32(v4[:], ^binary.BigEndian.Uint32(b[12:16]))
+		res.TeredoServer = netip.AddrFrom4([4]byte{b[4], b[5], b[6], b[7]}).String()
+		res.TeredoPort = ^binary.BigEndian.Uint16(b[10:12])
+	case "64:ff9b::/96", "::ffff:0:0/96":
+		copy(v4[:], b[12:16])
+	case "64:ff9b:1::/48":
+		// the local-use prefix length is chosen by the network, the usual /96 is assumed
+		copy(v4[:], b[12:16])
+	default:
+		return res, nil
+	}
+
+	res.Mechanism = s.Type
+	res.IPv4 = netip.AddrFrom4(v4).String()
+	return res, nil
+}
+
+// returns the embedded IPv4 address to look up instead of the IP address with --embedded.
+func embeddedLookupIP(ip string) string {
+	if !lookupEmbedded {
+		return ip
+	}
+
+	e, err := DecodeIPv6(ip)
+	if err != nil || e.IPv4 == "" {
+		return ip
+	}
+	return e.IPv4
+}
+
+func PrintDecode(args []string) int {
+	ips, err := ReadIPs(args)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	var w *tabwriter.Writer
+	if outputFormat != "json" {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "IP\tMECHANISM\tIPV4\tTEREDO SERVER\tTEREDO PORT")
+		defer w.Flush()
+	}
+
+	for _, ip := range ips {
+		e, err := DecodeIPv6(ip)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		if w != nil {
+			ipv4, server, port := "-", "-", "-"
+			if e.IPv4 != "" {
+				ipv4 = e.IPv4
+			}
+			if e.TeredoServer != "" {
+				server, port = e.TeredoServer, strconv.Itoa(int(e.TeredoPort))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.IP, e.Mechanism, ipv4, server, port)
+			continue
+		}
+
+		byteValue, err := json.Marshal(e)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Printf("%s\n", byteValue)
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDecodeIPv6(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want EmbeddedIPv4
+	}{
+		{"2002:c000:204::1", EmbeddedIPv4{"2002:c000:204::1", "6to4", "192.0.2.4", "", 0}},
+		{"2002:ffff:ffff:1::", EmbeddedIPv4{"2002:ffff:ffff:1::", "6to4", "255.255.255.255", "", 0}},
+		{"2001:0:4136:e378:8000:63bf:3fff:fdd2", EmbeddedIPv4{"2001:0:4136:e378:8000:63bf:3fff:fdd2", "teredo", "192.0.2.45", "65.54.227.120", 40000}},
+		{"64:ff9b::c000:221", EmbeddedIPv4{"64:ff9b::c000:221", "nat64", "192.0.2.33", "", 0}},
+		{"::ffff:8.8.8.8", EmbeddedIPv4{"::ffff:8.8.8.8", "ipv4-mapped", "8.8.8.8", "", 0}},
+		{"64:ff9b:1::c000:221", EmbeddedIPv4{"64:ff9b:1::c000:221", "nat64", "192.0.2.33", "", 0}},
+		{"2001:2::1", EmbeddedIPv4{"2001:2::1", "none", "", "", 0}},
+		{"2606:4700::1111", EmbeddedIPv4{"2606:4700::1111", "none", "", "", 0}},
+	}
+
+	for _, tt := range tests {
+		got, err := DecodeIPv6(tt.ip)
+		if err != nil || got != tt.want {
+			t.Errorf("DecodeIPv6(%q) = %+v, %v, want %+v", tt.ip, got, err, tt.want)
+		}
+	}
+
+	for _, ip := range []string{"8.8.8.8", "fe80::1%eth0", "x"} {
+		if _, err := DecodeIPv6(ip); err == nil {
+			t.Errorf("DecodeIPv6(%q) error = nil, want error", ip)
+		}
+	}
+}
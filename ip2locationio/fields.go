@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// The FieldInfo struct stores the details of a field in the API response.
+type FieldInfo struct {
+	Path        string `json:"field"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Plan        string `json:"plan"`
+}
+
+// plans in ascending order, each plan also returns the fields of the previous plans
+var plans = []string{"free", "starter", "plus", "security"}
+
+var responseFields = []FieldInfo{
+	{"ip", "string", "IP address", "free"},
+	{"country_code", "string", "Two-character country code based on ISO 3166", "free"},
+	{"country_name", "string", "Country name based on ISO 3166", "free"},
+	{"region_name", "string", "Region or state name", "free"},
+	{"city_name", "string", "City name", "free"},
+	{"latitude", "number", "City latitude", "free"},
+	{"longitude", "number", "City longitude", "free"},
+	{"zip_code", "string", "ZIP or postal code", "free"},
+	{"time_zone", "string", "UTC time zone (with DST supported)", "free"},
+	{"asn", "string", "Autonomous system number", "free"},
+	{"as", "string", "Autonomous system name", "free"},
+	{"is_proxy", "boolean", "Whether the IP address is a proxy", "free"},
+	{"isp", "string", "Internet Service Provider or company name", "starter"},
+	{"domain", "string", "Internet domain name associated with the IP address", "starter"},
+	{"net_speed", "string", "Internet connection type", "starter"},
+	{"idd_code", "string", "International direct dialing code", "starter"},
+	{"area_code", "string", "Telephone area code", "starter"},
+	{"weather_station_code", "string", "Nearest weather station code", "starter"},
+	{"weather_station_name", "string", "Nearest weather station name", "starter"},
+	{"mcc", "string", "Mobile country code", "starter"},
+	{"mnc", "string", "Mobile network code", "starter"},
+	{"mobile_brand", "string", "Commercial brand associated with the mobile carrier", "starter"},
+	{"elevation", "number", "Average height of city above sea level in meters", "starter"},
+	{"usage_type", "string", "Usage type classification of ISP or company", "starter"},
+	{"address_type", "string", "IP address type (Anycast, Unicast, Multicast or Broadcast)", "plus"},
+	{"ads_category", "string", "IAB content taxonomy code of the domain", "plus"},
+	{"ads_category_name", "string", "IAB content taxonomy name of the domain", "plus"},
+	{"district", "string", "District or county name", "plus"},
+	{"continent.name", "string", "Continent name", "plus"},
+	{"continent.code", "string", "Continent code", "plus"},
+	{"continent.hemisphere", "array", "Hemispheres of the continent", "plus"},
+	{"continent.translation.lang", "string", "Translation language of the continent name", "plus"},
+	{"continent.translation.value", "string", "Translated continent name", "plus"},
+	{"country.name", "string", "Country name", "plus"},
+	{"country.alpha3_code", "string", "Three-character country code based on ISO 3166", "plus"},
+	{"country.numeric_code", "number", "Numeric country code based on ISO 3166", "plus"},
+	{"country.demonym", "string", "Native of the country", "plus"},
+	{"country.flag", "string", "URL of the country flag image", "plus"},
+	{"country.capital", "string", "Capital of the country", "plus"},
+	{"country.total_area", "number", "Total area of the country in square kilometers", "plus"},
+	{"country.population", "number", "Population of the country", "plus"},
+	{"country.currency.code", "string", "Currency code based on ISO 4217", "plus"},
+	{"country.currency.name", "string", "Currency name", "plus"},
+	{"country.currency.symbol", "string", "Currency symbol", "plus"},
+	{"country.language.code", "string", "Language code based on ISO 639", "plus"},
+	{"country.language.name", "string", "Language name", "plus"},
+	{"country.tld", "string", "Country-code top-level domain", "plus"},
+	{"country.translation.lang", "string", "Translation language of the country name", "plus"},
+	{"country.translation.value", "string", "Translated country name", "plus"},
+	{"region.name", "string", "Region name", "plus"},
+	{"region.code", "string", "Region code based on ISO 3166-2", "plus"},
+	{"region.translation.lang", "string", "Translation language of the region name", "plus"},
+	{"region.translation.value", "string", "Translated region name", "plus"},
+	{"city.name", "string", "City name", "plus"},
+	{"city.translation.lang", "string", "Translation language of the city name", "plus"},
+	{"city.translation.value", "string", "Translated city name", "plus"},
+	{"time_zone_info.olson", "string", "Time zone in tz database format", "plus"},
+	{"time_zone_info.current_time", "string", "Current time in ISO 8601 format", "plus"},
+	{"time_zone_info.gmt_offset", "number", "GMT offset in seconds", "plus"},
+	{"time_zone_info.is_dst", "boolean", "Whether daylight saving time is in effect", "plus"},
+	{"time_zone_info.sunrise", "string", "Time of sunrise", "plus"},
+	{"time_zone_info.sunset", "string", "Time of sunset", "plus"},
+	{"geotargeting.metro", "string", "Metro code based on zip code", "plus"},
+	{"fraud_score", "number", "Potential risk score (0 - 99) associated with the IP address", "security"},
+	{"proxy.last_seen", "number", "Proxy last seen in days", "security"},
+	{"proxy.proxy_type", "string", "Type of proxy", "security"},
+	{"proxy.threat", "string", "Security threat reported", "security"},
+	{"proxy.provider", "string", "Name of VPN provider if available", "security"},
+	{"proxy.is_vpn", "boolean", "Anonymizing VPN services", "security"},
+	{"proxy.is_tor", "boolean", "Tor exit nodes", "security"},
+	{"proxy.is_data_center", "boolean", "Hosting provider, data center or content delivery network", "security"},
+	{"proxy.is_public_proxy", "boolean", "Public proxies", "security"},
+	{"proxy.is_web_proxy", "boolean", "Web based proxies", "security"},
+	{"proxy.is_web_crawler", "boolean", "Search engine robots", "security"},
+	{"proxy.is_residential_proxy", "boolean", "Residential proxies", "security"},
+	{"proxy.is_spammer", "boolean", "Email and forum spammers", "security"},
+	{"proxy.is_scanner", "boolean", "Network security scanners", "security"},
+	{"proxy.is_botnet", "boolean", "Malware infected devices", "security"},
+}
+
+// Fields returns the response fields available in the plan,
+// or all the response fields if the plan is empty.
+func Fields(plan string) ([]FieldInfo, error) {
+	if plan == "" {
+		return responseFields, nil
+	}
+
+	rank := planRank(plan)
+	if rank == -1 {
+		return nil, errors.New("Invalid plan. Valid values: " + strings.Join(plans, " | "))
+	}
+
+	var res []FieldInfo
+	for _, f := range responseFields {
+		if planRank(f.Plan) <= rank {
+			res = append(res, f)
+		}
+	}
+
+	return res, nil
+}
+
+// FieldPaths returns the paths of all the response fields.
+func FieldPaths() []string {
+	res := make([]string, len(responseFields))
+	for i, f := range responseFields {
+		res[i] = f.Path
+	}
+	return res
+}
+
+// returns the position of the plan in the list of plans or -1 if not found.
+func planRank(plan string) int {
+	for i, p := range plans {
+		if strings.EqualFold(p, plan) {
+			return i
+		}
+	}
+	return -1
+}
+
+func PrintFields(plan string) int {
+	res, err := Fields(plan)
+
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if outputFormat == "json" {
+		byteValue, err := json.Marshal(res)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Printf("%s\n", byteValue)
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIELD\tTYPE\tPLAN\tDESCRIPTION")
+	for _, f := range res {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Path, f.Type, f.Plan, f.Description)
+	}
+	w.Flush()
+	return 0
+}
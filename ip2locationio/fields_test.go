@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		plan    string
+		has     string
+		missing string
+	}{
+		{"free", "country_code", "isp"},
+		{"Starter", "isp", "proxy.is_vpn"},
+		{"security", "proxy.is_vpn", ""},
+		{"", "proxy.is_botnet", ""},
+	}
+
+	for _, tt := range tests {
+		fields, err := Fields(tt.plan)
+
+		paths := make(map[string]bool)
+		for _, f := range fields {
+			paths[f.Path] = true
+		}
+		if err != nil || !paths[tt.has] || paths[tt.missing] {
+			t.Errorf("Fields(%q) = %v, %v, want %s without %s", tt.plan, fields, err, tt.has, tt.missing)
+		}
+	}
+
+	if _, err := Fields("gold"); err == nil {
+		t.Error(`Fields("gold") error = nil, want error`)
+	}
+}
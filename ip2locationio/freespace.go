@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FreeSpace returns the ranges of the parent CIDR which are not in the used CIDRs, ranges and IP addresses.
+func FreeSpace(parent string, used []string) ([]ipRange, error) {
+	r, err := ParseIPRange(parent)
+	if err != nil || !strings.Contains(parent, "/") {
+		return nil, errors.New("Not a valid CIDR.")
+	}
+
+	ranges, err := ParseIPRanges(used)
+	if err != nil {
+		return nil, err
+	}
+
+	return excludeRanges([]ipRange{r}, mergeRanges(ranges)), nil
+}
+
+// FreeSubnets returns the iterator of the subnets with the prefix length in the free ranges.
+func FreeSubnets(free []ipRange, size int) (*SubnetIterator, error) {
+	var blocks []prefix
+	ipv6 := false
+
+	for _, r := range free {
+		width := 32
+		if r.IPv6 {
+			width = 128
+		}
+		if size < 0 || size > width {
+			return nil, errors.New("Invalid size.")
+		}
+
+		// the minimal CIDRs of a range contain all the aligned subnets in it
+		blocks = append(blocks, rangeToPrefixes(r.Start, r.End, width)...)
+		ipv6 = r.IPv6
+	}
+
+	return NewSubnetIterator(blocks, ipv6, size), nil
+}
+
+func PrintFreeSpace(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Not a valid CIDR.")
+		return 1
+	}
+
+	if err := checkStdin(args[1:]); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	var used []string
+	for _, list := range args[1:] {
+		entries, err := ReadList(list)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		used = append(used, entries...)
+	}
+
+	free, err := FreeSpace(args[0], used)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if freeSize == "" && freeNext {
+		fmt.Println("Use --next with --size.")
+		return 1
+	} else if freeSize == "" {
+		for _, r := range free {
+			for _, cidr := range r.CIDRs() {
+				fmt.Println(cidr)
+			}
+		}
+		return 0
+	}
+
+	size, err := strconv.Atoi(strings.TrimPrefix(freeSize, "/"))
+	if err != nil {
+		fmt.Println("Invalid size.")
+		return 1
+	}
+
+	it, err := FreeSubnets(free, size)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if it.Count().Sign() == 0 {
+		fmt.Printf("No free /%d subnet in %s.\n", size, args[0])
+		return 1
+	} else if freeNext {
+		subnet, _ := it.Next()
+		fmt.Println(subnet)
+		return 0
+	}
+	return PrintSubnets(it)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFreeSpace(t *testing.T) {
+	tests := []struct {
+		parent  string
+		used    []string
+		size    int
+		first   bool
+		want    []string
+		wantErr bool
+	}{
+		{"10.0.0.0/22", []string{"10.0.1.0/24"}, -1, false, []string{"10.0.0.0/24", "10.0.2.0/23"}, false},
+		{"10.0.0.0/22", []string{"10.0.1.0/24", "192.168.0.0/16", "2001:db8::/32"}, -1, false, []string{"10.0.0.0/24", "10.0.2.0/23"}, false},
+		{"10.0.0.0/24", []string{"10.0.0.0/23"}, -1, false, nil, false},
+		{"10.0.0.0/24", nil, -1, false, []string{"10.0.0.0/24"}, false},
+		{"2001:db8::/32", []string{"2001:db8::/33"}, -1, false, []string{"2001:db8:8000::/33"}, false},
+		{"10.0.0.0/20", []string{"10.0.0.0/24", "10.0.4.0/22", "10.0.10.0/23"}, 23, false, []string{"10.0.2.0/23", "10.0.8.0/23", "10.0.12.0/23", "10.0.14.0/23"}, false},
+		{"10.0.0.0/16", []string{"10.0.0.0/24", "10.0.1.0-10.0.1.10"}, 24, true, []string{"10.0.2.0/24"}, false},
+		{"10.0.0.0/16", []string{"10.0.0.0/24"}, 8, true, nil, false},
+		{"0.0.0.0/0", []string{"0.0.0.0/8", "10.0.0.0/8"}, 32, true, []string{"1.0.0.0/32"}, false},
+		{"::/0", []string{"::/1"}, 128, true, []string{"8000::/128"}, false},
+		{"10.0.0.0/24", nil, 33, false, nil, true},
+		{"10.0.0.1", nil, -1, false, nil, true},
+		{"10.0.0.0/24", []string{"x"}, -1, false, nil, true},
+	}
+
+	for _, tt := range tests {
+		free, err := FreeSpace(tt.parent, tt.used)
+
+		var got []string
+		if err == nil && tt.size >= 0 {
+			var it *SubnetIterator
+			if it, err = FreeSubnets(free, tt.size); err == nil {
+				for subnet, ok := it.Next(); ok; subnet, ok = it.Next() {
+					got = append(got, subnet)
+					if tt.first {
+						break
+					}
+				}
+			}
+		} else {
+			for _, r := range free {
+				got = append(got, r.CIDRs()...)
+			}
+		}
+
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("free space in %q without %v = %v, %v, want %v", tt.parent, tt.used, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// the sources of the fields of a hybrid lookup result
+const (
+	sourceDatabase = "database"
+	sourceAPI      = "api"
+)
+
+// the top-level fields used by -f and --where for --hybrid, where nil means all of them
+var hybridFields []string
+
+// HybridLookUp returns the result from the database merged with the API result for the fields which are not
+// in the database, where nil fields means all of them. If the database is older than maxAge days and maxAge is
+// more than 0, the API is always used and its values replace the database ones. The "sources" field of the
+// result has "database" or "api" for each field. The database result is used if the API lookup fails,
+// and the API result if the IP address is not in the database.
+func HybridLookUp(db *BinDB, ip string, fields []string, maxAge int) (string, error) {
+	var keys []string
+	var values map[string]json.RawMessage
+	sources := make(map[string]string)
+
+	local, err := db.LookUp(ip)
+	var miss binMissError
+	found := !errors.As(err, &miss)
+	if !found {
+		keys, values, err = apiFields(ip)
+	} else if err == nil {
+		keys, values, err = jsonFields(local)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, key := range keys {
+		if found {
+			sources[key] = sourceDatabase
+		} else {
+			sources[key] = sourceAPI
+		}
+	}
+
+	stale := maxAge > 0 && time.Since(db.Date()) > time.Duration(maxAge)*24*time.Hour
+	if found && (stale || missingFields(values, fields)) {
+		apiKeys, apiValues, err := apiFields(ip)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s. Using the local database only.\n", ip, strings.TrimSuffix(err.Error(), "."))
+		}
+
+		for _, key := range apiKeys {
+			if _, ok := values[key]; !ok {
+				keys = append(keys, key)
+			} else if !stale {
+				continue
+			}
+			values[key] = apiValues[key]
+			sources[key] = sourceAPI
+		}
+	}
+
+	return hybridJSON(keys, values, sources), nil
+}
+
+// returns the JSON object with the fields in their order followed by the "sources" field.
+func hybridJSON(keys []string, values map[string]json.RawMessage, sources map[string]string) string {
+	var buf bytes.Buffer
+	var sourcesBuf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		name, _ := json.Marshal(key)
+		source, _ := json.Marshal(sources[key])
+		if i > 0 {
+			sourcesBuf.WriteByte(',')
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(values[key])
+		buf.WriteByte(',')
+		sourcesBuf.Write(name)
+		sourcesBuf.WriteByte(':')
+		sourcesBuf.Write(source)
+	}
+
+	return buf.String() + `"sources":{` + sourcesBuf.String() + "}}"
+}
+
+// returns true if any of the fields is not in the result, where nil fields means all of them.
+func missingFields(values map[string]json.RawMessage, fields []string) bool {
+	if fields == nil {
+		return true
+	}
+
+	for _, field := range fields {
+		if _, ok := values[field]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the fields of the API lookup result in their order.
+func apiFields(ip string) ([]string, map[string]json.RawMessage, error) {
+	res, err := LookUpJSON(ip, myLanguage)
+	if err != nil {
+		return nil, nil, err
+	}
+	return jsonFields(res)
+}
+
+// returns the top-level fields of the JSON object in their order, and their values.
+func jsonFields(str string) ([]string, map[string]json.RawMessage, error) {
+	invalid := errors.New("Not a valid lookup result.")
+	dec := json.NewDecoder(strings.NewReader(str))
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, nil, invalid
+	}
+
+	var keys []string
+	values := make(map[string]json.RawMessage)
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, nil, invalid
+		}
+		key, _ := t.(string)
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, invalid
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+
+	return keys, values, nil
+}
+
+// returns the top-level fields used by the -f selectors and the where expression,
+// or nil if all fields are used.
+func usedFields(sels []Selector, where Expr) []string {
+	if sels == nil {
+		return nil
+	}
+
+	var paths [][]string
+	for _, sel := range sels {
+		paths = append(paths, sel.Segments)
+	}
+	for _, path := range exprFields(where) {
+		if segments, err := parsePath(path); err == nil {
+			paths = append(paths, segments)
+		}
+	}
+
+	res := []string{}
+	for _, segments := range paths {
+		// the fields added after the lookup do not need the API
+		if segments[0] == "*" {
+			return nil
+		} else if segments[0] != "rdns" && segments[0] != "sources" {
+			res = append(res, segments[0])
+		}
+	}
+	return res
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestUsedFields(t *testing.T) {
+	tests := []struct {
+		fields string
+		where  string
+		want   []string
+	}{
+		{"ip,country_code as cc,continent.hemisphere[0]", "", []string{"ip", "country_code", "continent"}},
+		{"ip,rdns.names,sources.proxy", `proxy.is_vpn && !(asn == "15169")`, []string{"ip", "proxy", "asn"}},
+		{"ip,*", "", nil},
+		{"", "", nil},
+	}
+
+	for _, tt := range tests {
+		var sels []Selector
+		if tt.fields != "" {
+			sels, _ = ParseSelectors(tt.fields)
+		}
+		var where Expr
+		if tt.where != "" {
+			where, _ = ParseWhere(tt.where)
+		}
+
+		if got := usedFields(sels, where); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("usedFields(%q, %q) = %v, want %v", tt.fields, tt.where, got, tt.want)
+		}
+	}
+}
+
+func TestJSONFields(t *testing.T) {
+	keys, values, err := jsonFields(`{"ip":"8.8.8.8","proxy":{"is_vpn":false},"asn":"15169","elevation":32}`)
+	if err != nil || !reflect.DeepEqual(keys, []string{"ip", "proxy", "asn", "elevation"}) || string(values["proxy"]) != `{"is_vpn":false}` {
+		t.Errorf("jsonFields = %v, %v, %v", keys, values, err)
+	}
+
+	for _, str := range []string{"", "[]", `{"ip":}`} {
+		if _, _, err := jsonFields(str); err == nil {
+			t.Errorf("jsonFields(%q) error = nil, want error", str)
+		}
+	}
+}
+
+func TestHybridJSON(t *testing.T) {
+	values := map[string]json.RawMessage{"ip": json.RawMessage(`"8.8.8.8"`), "asn": json.RawMessage(`"15169"`)}
+	sources := map[string]string{"ip": sourceDatabase, "asn": sourceAPI}
+
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"ip", "asn"}, `{"ip":"8.8.8.8","asn":"15169","sources":{"ip":"database","asn":"api"}}`},
+		{nil, `{"sources":{}}`},
+	}
+
+	for _, tt := range tests {
+		if got := hybridJSON(tt.keys, values, sources); got != tt.want {
+			t.Errorf("hybridJSON(%v) = %s, want %s", tt.keys, got, tt.want)
+		}
+	}
+}
+
+func TestHybridLookUp(t *testing.T) {
+	db, err := OpenBinDB(writeBinDB(t, buildBinDB(1, binTestRows4, nil, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		fields []string
+		maxAge int
+		want   string
+	}{
+		// the database has all the fields
+		{[]string{"ip", "country_code"}, 0, `{"ip":"8.8.8.8","country_code":"US","country_name":"United States of America","sources":{"ip":"database","country_code":"database","country_name":"database"}}`},
+		{[]string{"country_code"}, 36500, `{"ip":"8.8.8.8","country_code":"US","country_name":"United States of America","sources":{"ip":"database","country_code":"database","country_name":"database"}}`},
+	}
+
+	for _, tt := range tests {
+		if got, err := HybridLookUp(db, "8.8.8.8", tt.fields, tt.maxAge); err != nil || got != tt.want {
+			t.Errorf("HybridLookUp(%v, %d) = %s, %v, want %s", tt.fields, tt.maxAge, got, err, tt.want)
+		}
+	}
+
+	// the API is used for the missing fields, or for all of them when the database is stale
+	for _, maxAge := range []int{0, 1} {
+		got, err := HybridLookUp(db, "8.8.8.8", []string{"ip", "country_code", "city_name"}, maxAge)
+		ipl, _ := JSONToMap(got)
+		if err != nil || ipl["city_name"] != "Mountain View" {
+			t.Fatalf("HybridLookUp(%d) = %s, %v", maxAge, got, err)
+		}
+
+		sources := ipl["sources"].(map[string]interface{})
+		want := map[string]interface{}{"country_code": "database", "city_name": "api", "proxy": "api"}
+		if maxAge > 0 {
+			want["country_code"] = "api"
+		}
+		for key, source := range want {
+			if sources[key] != source {
+				t.Errorf("HybridLookUp(%d) sources[%s] = %v, want %v", maxAge, key, sources[key], source)
+			}
+		}
+	}
+
+}
+
+func TestHybridLookUpNotInDatabase(t *testing.T) {
+	db, err := OpenBinDB(writeBinDB(t, buildBinDB(1, binTestRows4[3:], nil, false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the API result is used for the IP addresses which are not in the database
+	got, err := HybridLookUp(db, "1.1.1.1", []string{"ip", "country_code"}, 0)
+	ipl, _ := JSONToMap(got)
+	if err != nil || ipl["city_name"] != "Brisbane" || ipl["sources"].(map[string]interface{})["country_code"] != "api" {
+		t.Errorf("HybridLookUp(1.1.1.1) = %s, %v", got, err)
+	}
+
+	if _, err := HybridLookUp(db, "2001:db8::1", []string{"ip"}, 0); err == nil {
+		t.Errorf("HybridLookUp(2001:db8::1) error = nil, want the API error")
+	}
+
+}
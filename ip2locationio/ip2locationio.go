@@ -1,25 +1,65 @@
 package main
 
 import (
-	"flag"
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
-var outputFormat string
+var outputFormat string = "json"
 var apiKey string
 var myLanguage string
-var myIP string
+var myIPs []string
 var filterFields string
+var whereExpr string
+var specialMode string
+var lookupEmbedded bool
+var lookupRDNS bool
+var rdnsResolver string
+var lookupDB string
+var lookupHybrid bool
+var hybridMaxAge int
 
 const version string = "1.2.0"
 const programName string = "IP2Location.io Command Line"
 
 var showVer bool = false
 
+var listLimit uint64
+var listOffset uint64
+var listForce bool
+
+var containsInvert bool
+
+var splitSubnets uint64
+var splitHosts uint64
+
+var freeSize string
+var freeNext bool
+
+var randCount uint64 = 1
+var randIPv6 bool
+var randWithin string
+var randPublicOnly bool
+var randSeed string
+
+var convertTo string
+var convertIPv6 bool
+
+var asnRoutes string
+var asnGeolocate bool
+
+const listThreshold int64 = 1048576
+
+var languages = []string{"ar", "cs", "da", "de", "en", "es", "et", "fi", "fr", "ga", "it", "ja", "ko", "ms", "nl", "pt", "ru", "sv", "tr", "vi", "zh-cn", "zh-tw"}
+var outputFormats = []string{"json", "pretty"}
+
 func init() {
 	maxIPv4Range = big.NewInt(4294967295)
 	maxIPv6Range = big.NewInt(0)
@@ -31,64 +71,145 @@ func init() {
 }
 
 func main() {
-	flag.StringVar(&outputFormat, "o", "json", "Output format: json | pretty")
-	flag.StringVar(&apiKey, "k", "", "API key: Get your API key from https://ip2location.io")
-	flag.StringVar(&myLanguage, "l", "", "Language: ar | cs | da | de | en | es | et | fi | fr | ga | it | ja | ko | ms | nl | pt | ru | sv | tr | vi | zh-cn | zh-tw")
-	flag.StringVar(&filterFields, "f", "", `Filter fields: Field names separted by comma. E.g., "country_code,city_name,continent.name"`)
-	flag.BoolVar(&showVer, "v", false, "Show version")
-
-	flag.Usage = func() {
-		PrintUsage()
-	}
-	flag.Parse()
-
-	if showVer {
-		PrintVersion()
-		return
-	}
-
-	if apiKey == "" {
-		apiKey = config.APIKey
-	}
-
-	var arg = flag.Arg(0)
-
-	if arg == "config" {
-		UpdateAPIKey(flag.Arg(1))
-		return
-	} else if arg == "randip" {
-		PrintRandIP()
-		return
-	} else if arg == "cidr2list" {
-		PrintCIDR2List(flag.Arg(1))
-		return
-	} else if arg == "range2list" {
-		PrintRange2List(flag.Arg(1), flag.Arg(2))
-		return
-	} else if arg == "cidr2range" {
-		PrintCIDR2Range(flag.Arg(1))
-		return
-	} else if arg == "range2cidr" {
-		PrintRange2CIDR(flag.Arg(1), flag.Arg(2))
-		return
-	} else if arg == "splitcidr" {
-		PrintSplitCIDR(flag.Arg(1), flag.Arg(2))
-		return
-	} else if len(arg) == 0 {
-		myIP = MyPublicIP()
-	} else if !IsIPv4(arg) && !IsIPv6(arg) {
-		fmt.Println("Not a valid IP address.")
-		return
+	os.Exit(Run(os.Args[1:]))
+}
+
+// RunLookup queries the geolocation of the IP addresses, or the own public IP if none supplied.
+func RunLookup(args []string) int {
+	if len(args) == 0 && lookupDB != "" {
+		fmt.Println("No IP address supplied.")
+		return 1
+	} else if len(args) == 0 {
+		myIPs = []string{MyPublicIP()}
 	} else {
-		myIP = arg
+		ips, err := ReadIPs(args)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		myIPs = ips
+	}
+
+	var where Expr
+	if strings.TrimSpace(whereExpr) != "" {
+		e, err := ParseWhere(whereExpr)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		where = e
+	}
+
+	specialMode = strings.ToLower(specialMode)
+	if specialMode != "" && specialMode != "skip" && specialMode != "annotate" {
+		fmt.Println("Invalid special mode. Valid values: " + strings.Join(specialModes, " | "))
+		return 1
 	}
 
 	filterFields = strings.TrimSpace(filterFields)
 
+	if (lookupHybrid || hybridMaxAge != 0) && lookupDB == "" {
+		fmt.Println("Use --hybrid and --max-age with --db.")
+		return 1
+	} else if hybridMaxAge != 0 && !lookupHybrid {
+		fmt.Println("Use --max-age with --hybrid.")
+		return 1
+	} else if hybridMaxAge < 0 {
+		fmt.Println("Invalid maximum age.")
+		return 1
+	}
+
+	if lookupHybrid && filterFields != "" {
+		sels, _ := ParseSelectors(filterFields)
+		hybridFields = usedFields(sels, where)
+	} else {
+		hybridFields = nil
+	}
+
 	if filterFields != "" {
-		PrintFiltered()
+		return PrintFiltered(where)
+	}
+	return PrintNormal(where)
+}
+
+// ReadIPs returns the IP addresses to look up from the arguments, where "-" reads one IP address
+// per line from the standard input. The invalid lines of the standard input are reported on the
+// standard error and skipped.
+func ReadIPs(args []string) ([]string, error) {
+	var ips []string
+
+	for _, arg := range args {
+		if arg != "-" {
+			if !IsIPv4(arg) && !IsIPv6(arg) {
+				if len(args) == 1 {
+					return nil, errors.New("Not a valid IP address.")
+				}
+				return nil, errors.New("Not a valid IP address: " + arg)
+			}
+			ips = append(ips, arg)
+			continue
+		}
+
+		lines, err := readLines(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			if !IsIPv4(line) && !IsIPv6(line) {
+				fmt.Fprintln(os.Stderr, "Not a valid IP address: "+line)
+				continue
+			}
+			ips = append(ips, line)
+		}
+	}
+
+	if len(ips) == 0 {
+		return nil, errors.New("No IP address supplied.")
+	}
+	return ips, nil
+}
+
+// returns the non-empty lines of the file without the "#" comments.
+func readFileLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readLines(f)
+}
+
+// returns the non-empty lines without the "#" comments.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := stripComment(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// returns the line without the text after "#" and the surrounding spaces.
+func stripComment(line string) string {
+	if i := strings.Index(line, "#"); i != -1 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// prints the lookup error, prefixed with the IP in bulk mode.
+func printLookupError(ip string, err error) {
+	if len(myIPs) > 1 {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", ip, err)
 	} else {
-		PrintNormal()
+		fmt.Println(err)
 	}
 }
 
@@ -96,11 +217,7 @@ func PrintVersion() {
 	fmt.Printf("%s Version: %s\n", programName, version)
 }
 
-func PrintRandIP() {
-	fmt.Printf("%s\n", RandIP())
-}
-
-func PrintCIDR2List(cidr string) {
+func PrintCIDR2List(cidr string) int {
 	res, err := CIDRToIPv4(cidr)
 
 	if err != nil {
@@ -108,49 +225,89 @@ func PrintCIDR2List(cidr string) {
 
 		if err != nil {
 			fmt.Println(err)
-		} else {
-			res, err := ListIPv6(res[0], res[1])
-			if err != nil {
-				fmt.Println(err)
-			} else {
-				for _, element := range res {
-					fmt.Println(element)
-				}
-			}
+			return 1
 		}
-	} else {
-		res, err := ListIPv4(res[0], res[1])
+		it, err := NewIPv6Iterator(res[0], res[1])
 		if err != nil {
 			fmt.Println(err)
-		} else {
-			for _, element := range res {
-				fmt.Println(element)
-			}
+			return 1
 		}
+		return PrintIPs(it)
 	}
-}
 
-func PrintRange2List(fromIP string, toIP string) {
-	res, err := ListIPv4(fromIP, toIP)
+	it, err := NewIPv4Iterator(res[0], res[1])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return PrintIPs(it)
+}
 
+func PrintRange2List(args []string) int {
+	r, err := rangeArgs(args)
 	if err != nil {
-		res, err := ListIPv6(fromIP, toIP)
+		fmt.Println(err)
+		return 1
+	}
+	return PrintIPs(newIPIterator(r))
+}
 
-		if err != nil {
-			fmt.Println("Invalid IP addresses.")
-		} else {
-			for _, element := range res {
-				fmt.Println(element)
-			}
+// PrintIPs writes the IP addresses as they are generated, applying --offset and --limit,
+// and refuses to list more than listThreshold addresses unless --force is used.
+func PrintIPs(it *IPIterator) int {
+	it.Skip(listOffset)
+
+	if count, ok := listCount(it.Count()); !ok {
+		fmt.Printf("The range contains %s IP addresses which is more than %d. Use --limit to list fewer or --force to list all.\n", count.String(), listThreshold)
+		return 1
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	buf := make([]byte, 0, 64)
+	for n := uint64(0); listLimit == 0 || n < listLimit; n++ {
+		addr, ok := it.NextAddr()
+		if !ok {
+			break
 		}
-	} else {
-		for _, element := range res {
-			fmt.Println(element)
+		buf = append(addr.AppendTo(buf[:0]), '\n')
+		w.Write(buf)
+	}
+	return 0
+}
+
+// PrintSubnets writes the subnets as they are generated, applying --limit,
+// and refuses to list more than listThreshold subnets unless --force is used.
+func PrintSubnets(it *SubnetIterator) int {
+	if count, ok := listCount(it.Count()); !ok {
+		fmt.Printf("There are %s subnets which is more than %d. Use --limit to list fewer or --force to list all.\n", count.String(), listThreshold)
+		return 1
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+
+	for n := uint64(0); listLimit == 0 || n < listLimit; n++ {
+		subnet, ok := it.Next()
+		if !ok {
+			break
 		}
+		fmt.Fprintln(w, subnet)
+	}
+	return 0
+}
+
+// returns the number of entries to list out of the count with --limit,
+// and false if it is more than listThreshold without --force.
+func listCount(count *big.Int) (*big.Int, bool) {
+	if listLimit > 0 && count.Cmp(new(big.Int).SetUint64(listLimit)) > 0 {
+		count = new(big.Int).SetUint64(listLimit)
 	}
+	return count, listForce || count.Cmp(big.NewInt(listThreshold)) <= 0
 }
 
-func PrintCIDR2Range(cidr string) {
+func PrintCIDR2Range(cidr string) int {
 	res, err := CIDRToIPv4(cidr)
 
 	if err != nil {
@@ -158,138 +315,231 @@ func PrintCIDR2Range(cidr string) {
 
 		if err != nil {
 			fmt.Println(err)
-		} else {
-			fmt.Printf("%s-%s\n", res[0], res[1])
+			return 1
 		}
+		fmt.Printf("%s-%s\n", res[0], res[1])
 	} else {
 		fmt.Printf("%s-%s\n", res[0], res[1])
 	}
+	return 0
 }
 
-func PrintRange2CIDR(fromIP string, toIP string) {
-	res, err := IPv4ToCIDR(fromIP, toIP)
-
+func PrintRange2CIDR(args []string) int {
+	r, err := rangeArgs(args)
 	if err != nil {
-		res, err := IPv6ToCIDR(fromIP, toIP)
+		fmt.Println(err)
+		return 1
+	}
 
-		if err != nil {
-			fmt.Println("Invalid IP addresses.")
+	for _, element := range r.CIDRs() {
+		fmt.Println(element)
+	}
+	return 0
+}
+
+func PrintSplitCIDR(cidr string, split string) int {
+	options := 0
+	for _, set := range []bool{split != "", splitSubnets > 0, splitHosts > 0} {
+		if set {
+			options++
+		}
+	}
+	if options != 1 {
+		fmt.Println("Specify either SPLIT, --subnets or --hosts.")
+		return 1
+	}
+
+	if splitSubnets > 0 || splitHosts > 0 {
+		var prefixLen int
+		var err error
+		if splitSubnets > 0 {
+			prefixLen, err = SplitPrefixForSubnets(cidr, splitSubnets)
 		} else {
-			for _, element := range res {
-				fmt.Println(element)
-			}
+			prefixLen, err = SplitPrefixForHosts(cidr, splitHosts)
 		}
-	} else {
-		for _, element := range res {
-			fmt.Println(element)
+		if err != nil {
+			fmt.Println(err)
+			return 1
 		}
+		split = strconv.Itoa(prefixLen)
 	}
-}
-
-func PrintSplitCIDR(cidr string, split string) {
-	res, err := SplitCIDR(cidr, split)
 
+	it, err := NewSplitIterator(cidr, split)
 	if err != nil {
 		fmt.Println(err)
-	} else {
-		for _, element := range res {
-			fmt.Println(element)
-		}
+		return 1
 	}
+	return PrintSubnets(it)
 }
 
-func PrintFiltered() {
-	ipl, err := LookUpMap(myIP, myLanguage)
-
+// PrintFiltered writes the selected fields of the results as CSV,
+// returning 1 if any lookup failed.
+func PrintFiltered(where Expr) int {
+	sels, err := ParseSelectors(filterFields)
 	if err != nil {
 		fmt.Println(err)
-	} else {
-		var field string
-		fields := strings.Split(filterFields, ",")
-		for i := 0; i < len(fields); i++ {
-			field = strings.TrimSpace(fields[i])
-			fmt.Print(field)
-			if i+1 < len(fields) {
-				fmt.Print(",")
+		return 1
+	}
+
+	code := 0
+	var cols []Column
+	for _, queried := range myIPs {
+		var ipl map[string]interface{}
+		var err error
+
+		ip := embeddedLookupIP(queried)
+		if res, ok := specialLookup(ip); ok {
+			if res == "" {
+				continue
 			}
+			ipl, err = JSONToMap(res)
+		} else {
+			ipl, err = lookUpResultMap(ip)
 		}
-		fmt.Println("")
-		for i := 0; i < len(fields); i++ {
-			field = strings.TrimSpace(fields[i])
-			subfields := strings.Split(field, ".")
-
-			// traverse the nested map
-			var subfield string
-			iplsub := ipl
-			for j := 0; j < len(subfields); j++ {
-				subfield = subfields[j]
-
-				if v, exists := iplsub[subfield]; exists {
-					if v == nil {
-						break
-					}
-					if j+1 == len(subfields) { // end of the traversal
-						switch t := reflect.TypeOf(v).Kind(); t {
-						case reflect.String:
-							v2 := v.(string)
-							v2 = strings.ReplaceAll(v2, `"`, `\"`)
-							fmt.Printf(`"%s"`, v2)
-						case reflect.Float64:
-							v2 := v.(float64) // all numbers are converted to float
-							if subfield == "latitude" || subfield == "longitude" {
-								fmt.Print(v2) // maintain as float
-							} else {
-								fmt.Print(int(v2))
-							}
-						case reflect.Slice:
-							fmt.Printf("%v", v)
-						case reflect.Bool:
-							v2 := v.(bool)
-							fmt.Print(v2)
-						default:
-							fmt.Print("")
-						}
-					} else { // still need to drill down the map
-						iplsub = iplsub[subfield].(map[string]interface{})
-					}
-				} else {
-					break
-				}
+
+		if err != nil {
+			printLookupError(ip, err)
+			code = 1
+			continue
+		}
+
+		if ip != queried {
+			ipl["queried_ip"] = queried
+		}
+		if lookupRDNS {
+			addRDNSMap(ipl, ip)
+		}
+
+		if !Matches(where, ipl) {
+			continue
+		}
+
+		// the columns for wildcards are based on the first result
+		if cols == nil {
+			cols = ExpandSelectors(sels, ipl)
+			headers := make([]string, len(cols))
+			for i, col := range cols {
+				headers[i] = col.Header
 			}
-			if i+1 < len(fields) {
+			fmt.Println(strings.Join(headers, ","))
+		}
+
+		for i, col := range cols {
+			v, _ := resolvePath(ipl, col.Segments)
+			fmt.Print(FormatField(lastKey(col.Segments), v))
+			if i+1 < len(cols) {
 				fmt.Print(",")
 			}
 		}
 		fmt.Println("")
 	}
+	return code
 }
 
-func PrintNormal() {
-	json, err := LookUpJSON(myIP, myLanguage)
+// FormatField returns the CSV representation of the field value.
+func FormatField(subfield string, v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	switch t := reflect.TypeOf(v).Kind(); t {
+	case reflect.String:
+		v2 := v.(string)
+		v2 = strings.ReplaceAll(v2, `"`, `\"`)
+		return `"` + v2 + `"`
+	case reflect.Float64:
+		v2 := v.(float64) // all numbers are converted to float
+		if subfield == "latitude" || subfield == "longitude" {
+			return fmt.Sprint(v2) // maintain as float
+		}
+		return fmt.Sprint(int(v2))
+	case reflect.Slice:
+		return fmt.Sprintf("%v", v)
+	case reflect.Bool:
+		return fmt.Sprint(v.(bool))
+	}
+	return ""
+}
+
+// PrintNormal writes the results as JSON or pretty text, returning 1 if any lookup failed.
+func PrintNormal(where Expr) int {
+	code := 0
+	for _, queried := range myIPs {
+		var json string
+		var err error
+
+		ip := embeddedLookupIP(queried)
+		if res, ok := specialLookup(ip); ok {
+			if res == "" {
+				continue
+			}
+			json = res
+		} else {
+			json, err = lookUpResult(ip)
+		}
+
+		if err != nil {
+			printLookupError(ip, err)
+			code = 1
+			continue
+		}
+
+		if ip != queried {
+			json = addJSONField(json, "queried_ip", queried)
+		}
+		if lookupRDNS {
+			json = addRDNSJSON(json, ip)
+		}
+
+		if where != nil {
+			ipl, err := JSONToMap(json)
+			if err != nil {
+				printLookupError(ip, err)
+				code = 1
+				continue
+			}
+			if !Matches(where, ipl) {
+				continue
+			}
+		}
 
-	if err != nil {
-		fmt.Println(err)
-	} else {
 		if outputFormat == "json" {
 			fmt.Printf("%s\n", json)
-			return
+			continue
 		}
 		pretty, err := PrettyString(json)
 
 		if err != nil {
 			fmt.Println(err)
+			code = 1
 		} else {
 			fmt.Println(pretty)
 		}
 	}
+	return code
 }
 
 func PrintUsage() {
 	fmt.Printf("%s Version %s\n", programName, version)
-	var usage string = `
-To query IP geolocation:
 
-  Usage: EXE [OPTION]... <IP ADDRESS>
+	var sb strings.Builder
+	sb.WriteString("\nTo query IP geolocation:\n\n  Usage: EXE [OPTION]... <IP ADDRESS>...\n")
+	sb.WriteString(globalOptionsUsage)
+
+	for _, c := range commands {
+		if c.Name == "lookup" {
+			continue
+		}
+		sb.WriteString("\nTo " + strings.ToLower(c.Summary[:1]) + c.Summary[1:] + "\n\n")
+		sb.WriteString("  Usage: " + c.UsageLine() + "\n")
+	}
+	sb.WriteString("\nRun \"EXE help <COMMAND>\" to show the options and examples of a command.\n")
+
+	fmt.Println(strings.ReplaceAll(sb.String(), "EXE", os.Args[0]))
+}
+
+const globalOptionsUsage string = `
+    Multiple IP addresses can be supplied, or use "-" to read one IP address per line from standard input
 
     -v                   Display the version and exit
 
@@ -307,39 +557,35 @@ To query IP geolocation:
     -f                   Filter the result fields
                          Field names separated by comma and using period for nested field
                          E.g. country_name,region_code,continent.name,country.translation.value
+                         Use [n] to index arrays, * as wildcard and "as" to rename the column
+                         E.g. "continent.hemisphere[0],country.currency.*,country_code as cc"
 
-To store the API key
-
-  Usage: EXE config <API KEY>
-
-
-Other functions:
-
-To generate random IPv4 address
+    --where              Only output the results matching the expression
+                         Supports ==, !=, <, <=, >, >=, &&, ||, ! and parentheses
+                         E.g. 'country_code == "US" && proxy.is_vpn'
 
-  Usage: EXE randip
+    --special            Do not look up the special-purpose addresses such as private and loopback ones
+                         Valid values: skip | annotate (output the classification instead)
 
-To convert CIDR to range
+    --embedded           Look up the IPv4 address embedded in 6to4, Teredo, NAT64 and IPv4-mapped IPv6
+                         addresses instead, see the decode command, with the IPv6 address added to
+                         the result as the "queried_ip" field
 
-  Usage: EXE cidr2range <CIDR>
+    --rdns               Add the PTR names of the IP address and whether any of them is forward-confirmed,
+                         i.e. resolves back to the IP address, to the result as the "rdns" field
 
-To convert range to CIDR
+    --resolver           Use the DNS server at the address for --rdns instead of the system resolver
+                         E.g. 1.1.1.1 or 127.0.0.1:5353
 
-  Usage: EXE range2cidr <START IP> <END IP>
+    --db                 Look up the IP addresses in the local IP2Location BIN database file instead of
+                         the API, with the fields of the database type, e.g. IP2LOCATION-LITE-DB11.BIN
 
-To list out the IPs in a CIDR
+    --hybrid             Look up the IP addresses in the --db database first and use the API only for the
+                         result fields which are not in the database, i.e. the -f and --where fields or
+                         all fields without -f, adding the "sources" field with database or api for each
 
-  Usage: EXE cidr2list <CIDR>
+    --max-age            Use the API values in place of the database ones for --hybrid when the database
+                         is older than the number of days
 
-To list out the IPs in a range
-
-  Usage: EXE range2list <START IP> <END IP>
-
-To split a larger CIDR into smaller ones
-
-  Usage: EXE splitcidr <CIDR> <SPLIT>
+    The options can also be used with the other commands below, e.g. -o for check and fields
 `
-
-	usage = strings.ReplaceAll(usage, "EXE", os.Args[0])
-	fmt.Println(usage)
-}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The ipRange struct stores an inclusive range of IPv4 or IPv6 addresses.
+type ipRange struct {
+	Start uint128
+	End   uint128
+	IPv6  bool
+}
+
+// ParseIPRange returns the range for a CIDR, a "START-END" range or a single IP address.
+func ParseIPRange(str string) (ipRange, error) {
+	str = strings.TrimSpace(str)
+	invalid := errors.New("Not a valid CIDR, range or IP address: " + str)
+
+	if strings.Contains(str, "/") {
+		if res, err := CIDRToIPv4(str); err == nil {
+			return newIPRange(res[0], res[1], false), nil
+		}
+		if res, err := CIDRToIPv6(str); err == nil {
+			return newIPRange(res[0], res[1], true), nil
+		}
+		return ipRange{}, invalid
+	}
+
+	if i := strings.Index(str, "-"); i != -1 {
+		return ParseRangeEnds(strings.TrimSpace(str[:i]), strings.TrimSpace(str[i+1:]))
+	}
+
+	r, err := ParseRangeEnds(str, str)
+	if err != nil {
+		return ipRange{}, invalid
+	}
+	return r, nil
+}
+
+// ParseRangeEnds returns the range between the start and end IP addresses, where IPv4-mapped IPv6
+// addresses are IPv4 unless the other IP address is IPv6, e.g. for the end of ::/80.
+func ParseRangeEnds(from string, to string) (ipRange, error) {
+	start, err := netip.ParseAddr(from)
+	if err != nil || start.Zone() != "" {
+		return ipRange{}, errors.New("Not a valid IP address: " + from)
+	}
+	end, err := netip.ParseAddr(to)
+	if err != nil || end.Zone() != "" {
+		return ipRange{}, errors.New("Not a valid IP address: " + to)
+	}
+
+	ipv6 := start.Is6() && end.Is6() && !(start.Is4In6() && end.Is4In6())
+	if !ipv6 {
+		start, end = start.Unmap(), end.Unmap()
+		if start.Is6() || end.Is6() {
+			return ipRange{}, errors.New("Mixed IPv4 and IPv6 addresses: " + from + "-" + to)
+		}
+	}
+
+	r := ipRange{uint128FromAddr(start), uint128FromAddr(end), ipv6}
+	if r.Start.Cmp(r.End) > 0 {
+		return ipRange{}, errors.New("The start IP address is after the end IP address: " + from + "-" + to)
+	}
+	return r, nil
+}
+
+// returns the range supplied as a "START-END" argument or as the start and end IP address arguments.
+func rangeArgs(args []string) (ipRange, error) {
+	if len(args) == 1 && strings.Contains(args[0], "-") {
+		i := strings.Index(args[0], "-")
+		from, to := strings.TrimSpace(args[0][:i]), strings.TrimSpace(args[0][i+1:])
+		if from == "" {
+			return ipRange{}, errors.New("No start IP address supplied.")
+		} else if to == "" {
+			return ipRange{}, errors.New("No end IP address supplied.")
+		}
+		return ParseRangeEnds(from, to)
+	}
+
+	if len(args) == 0 {
+		return ipRange{}, errors.New("No range supplied.")
+	} else if len(args) == 1 {
+		return ipRange{}, errors.New("No end IP address supplied.")
+	} else if len(args) > 2 {
+		return ipRange{}, errors.New("Too many arguments. Use START-END or START IP END IP.")
+	}
+	return ParseRangeEnds(args[0], args[1])
+}
+
+// returns the range between the validated IP addresses, keeping the IPv4-mapped addresses of IPv6 ranges.
+func newIPRange(from string, to string, ipv6 bool) ipRange {
+	start, _ := netip.ParseAddr(from)
+	end, _ := netip.ParseAddr(to)
+	if !ipv6 {
+		start, end = start.Unmap(), end.Unmap()
+	}
+	return ipRange{uint128FromAddr(start), uint128FromAddr(end), ipv6}
+}
+
+// CIDRs returns the minimal list of CIDRs covering the range.
+func (r ipRange) CIDRs() []string {
+	var res []string
+	width := 32
+	if r.IPv6 {
+		width = 128
+	}
+
+	for _, p := range rangeToPrefixes(r.Start, r.End, width) {
+		res = append(res, p.Start.Addr(r.IPv6).String()+"/"+strconv.Itoa(p.Bits))
+	}
+
+	return res
+}
+
+// returns the ranges sorted with IPv4 first, with the overlapping and adjacent ranges merged.
+func mergeRanges(ranges []ipRange) []ipRange {
+	sorted := append([]ipRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].IPv6 != sorted[j].IPv6 {
+			return !sorted[i].IPv6
+		}
+		return sorted[i].Start.Cmp(sorted[j].Start) < 0
+	})
+
+	var res []ipRange
+	for _, r := range sorted {
+		if n := len(res); n > 0 && res[n-1].IPv6 == r.IPv6 {
+			last := &res[n-1]
+			next, overflow := last.End.Add(uint128{0, 1})
+			if overflow || r.Start.Cmp(next) <= 0 {
+				if r.End.Cmp(last.End) > 0 {
+					last.End = r.End
+				}
+				continue
+			}
+		}
+		res = append(res, r)
+	}
+
+	return res
+}
+
+// ParseIPRanges returns the ranges for the CIDRs, ranges and IP addresses.
+func ParseIPRanges(entries []string) ([]ipRange, error) {
+	var res []ipRange
+
+	for _, entry := range entries {
+		r, err := ParseIPRange(entry)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+
+	return res, nil
+}
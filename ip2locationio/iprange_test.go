@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseIPRange(t *testing.T) {
+	tests := []struct {
+		str     string
+		start   string
+		end     string
+		wantErr bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0", "10.255.255.255", false},
+		{"10.1.2.3/16", "10.1.0.0", "10.1.255.255", false},
+		{"192.168.1.1-192.168.1.10", "192.168.1.1", "192.168.1.10", false},
+		{" 192.168.1.1 - 192.168.1.10 ", "192.168.1.1", "192.168.1.10", false},
+		{"8.8.8.8", "8.8.8.8", "8.8.8.8", false},
+		{"::ffff:8.8.8.8", "8.8.8.8", "8.8.8.8", false},
+		{"2001:db8::/32", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", false},
+		{"2001:db8::1-2001:db8::ff", "2001:db8::1", "2001:db8::ff", false},
+		{"::1", "::1", "::1", false},
+		{"::/80", "::", "::ffff:255.255.255.255", false},
+		{"::-::ffff:255.255.255.255", "::", "::ffff:255.255.255.255", false},
+		{"::ffff:10.0.0.1-10.0.0.2", "10.0.0.1", "10.0.0.2", false},
+		{"10.0.0.10-10.0.0.1", "", "", true},
+		{"10.0.0.1-2001:db8::1", "", "", true},
+		{"10.0.0.0/33", "", "", true},
+		{"10.0.0.0/", "", "", true},
+		{"fe80::1%eth0", "", "", true},
+		{"x", "", "", true},
+		{"", "", "", true},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseIPRange(tt.str)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIPRange(%q) error = %v, wantErr %v", tt.str, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if start, end := r.Start.Addr(r.IPv6).String(), r.End.Addr(r.IPv6).String(); start != tt.start || end != tt.end {
+			t.Errorf("ParseIPRange(%q) = %s-%s, want %s-%s", tt.str, start, end, tt.start, tt.end)
+		}
+	}
+}
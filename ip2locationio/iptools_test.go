@@ -0,0 +1,408 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestIsIPv4(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"0.0.0.0", true},
+		{"255.255.255.255", true},
+		{"::ffff:1.2.3.4", true},
+		{"256.1.1.1", false},
+		{"1.2.3", false},
+		{"2001:db8::1", false},
+		{"fe80::1%eth0", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsIPv4(tt.ip); got != tt.want {
+			t.Errorf("IsIPv4(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestIsIPv6(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"2001:db8::1", true},
+		{"::", true},
+		{"::1", true},
+		{"::ffff:1.2.3.4", false},
+		{"8.8.8.8", false},
+		{"fe80::1%eth0", false},
+		{"2001:db8:::1", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsIPv6(tt.ip); got != tt.want {
+			t.Errorf("IsIPv6(%q) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestCIDRToIPv4(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		want    []string
+		wantErr bool
+	}{
+		{"10.0.0.0/8", []string{"10.0.0.0", "10.255.255.255"}, false},
+		{"192.168.1.77/24", []string{"192.168.1.0", "192.168.1.255"}, false},
+		{"0.0.0.0/0", []string{"0.0.0.0", "255.255.255.255"}, false},
+		{"255.255.255.255/32", []string{"255.255.255.255", "255.255.255.255"}, false},
+		{"128.0.0.0/1", []string{"128.0.0.0", "255.255.255.255"}, false},
+		{"1.2.3.4/33", nil, true},
+		{"1.2.3.4", nil, true},
+		{"1.2.3.4/x", nil, true},
+		{"2001:db8::/32", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := CIDRToIPv4(tt.cidr)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CIDRToIPv4(%q) = %v, %v, want %v", tt.cidr, got, err, tt.want)
+		}
+	}
+}
+
+func TestCIDRToIPv6(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		want    []string
+		wantErr bool
+	}{
+		{"2001:db8::/32", []string{"2001:0db8:0000:0000:0000:0000:0000:0000", "2001:0db8:ffff:ffff:ffff:ffff:ffff:ffff"}, false},
+		{"2001:db8::1/64", []string{"2001:0db8:0000:0000:0000:0000:0000:0000", "2001:0db8:0000:0000:ffff:ffff:ffff:ffff"}, false},
+		{"::/0", []string{"0000:0000:0000:0000:0000:0000:0000:0000", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}, false},
+		{"::1/128", []string{"0000:0000:0000:0000:0000:0000:0000:0001", "0000:0000:0000:0000:0000:0000:0000:0001"}, false},
+		{"2001:db8::/129", nil, true},
+		{"10.0.0.0/8", nil, true},
+		{"2001:db8::", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := CIDRToIPv6(tt.cidr)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CIDRToIPv6(%q) = %v, %v, want %v", tt.cidr, got, err, tt.want)
+		}
+	}
+}
+
+func TestIPv4ToCIDR(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want []string
+	}{
+		{"10.0.0.0", "10.0.3.255", []string{"10.0.0.0/22"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"255.255.255.255", "255.255.255.255", []string{"255.255.255.255/32"}},
+		{"192.168.0.255", "192.168.1.0", []string{"192.168.0.255/32", "192.168.1.0/32"}},
+	}
+
+	for _, tt := range tests {
+		got, err := IPv4ToCIDR(tt.from, tt.to)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("IPv4ToCIDR(%q, %q) = %v, %v, want %v", tt.from, tt.to, got, err, tt.want)
+		}
+	}
+
+	for _, rng := range [][2]string{{"10.0.0.0", "2001:db8::"}, {"10.0.0.6", "10.0.0.1"}, {"10.0.0.1", "x"}} {
+		if _, err := IPv4ToCIDR(rng[0], rng[1]); err == nil {
+			t.Errorf("IPv4ToCIDR(%q, %q) error = nil, want error", rng[0], rng[1])
+		}
+	}
+}
+
+func TestIPv6ToCIDR(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		want []string
+	}{
+		{"2001:db8::", "2001:db8::ffff", []string{"2001:db8::/112"}},
+		{"2001:db8::1", "2001:db8::6", []string{"2001:db8::1/128", "2001:db8::2/127", "2001:db8::4/127", "2001:db8::6/128"}},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", []string{"::/0"}},
+		{"::1", "::1", []string{"::1/128"}},
+		{"::", "::ffff:255.255.255.255", []string{"::/80"}},
+		{"::ffff:0.0.0.0", "::1:0:0:0", []string{"::ffff:0.0.0.0/96", "::1:0:0:0/128"}},
+	}
+
+	for _, tt := range tests {
+		got, err := IPv6ToCIDR(tt.from, tt.to)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("IPv6ToCIDR(%q, %q) = %v, %v, want %v", tt.from, tt.to, got, err, tt.want)
+		}
+	}
+
+	for _, rng := range [][2]string{{"2001:db8::6", "2001:db8::1"}, {"::ffff:1.2.3.4", "::ffff:1.2.3.5"}, {"::", "10.0.0.1"}} {
+		if _, err := IPv6ToCIDR(rng[0], rng[1]); err == nil {
+			t.Errorf("IPv6ToCIDR(%q, %q) error = nil, want error", rng[0], rng[1])
+		}
+	}
+}
+
+func TestExpandIPv6(t *testing.T) {
+	got, err := ExpandIPv6("2001:db8::ff00:42:8329")
+	if err != nil || got != "2001:0db8:0000:0000:0000:ff00:0042:8329" {
+		t.Errorf("ExpandIPv6 = %q, %v", got, err)
+	}
+
+	if _, err := ExpandIPv6("8.8.8.8"); err == nil {
+		t.Errorf("ExpandIPv6 with IPv4 should fail")
+	}
+}
+
+func TestDecimalConversions(t *testing.T) {
+	n, _ := IPv4ToDecimal("8.8.8.8")
+	if n.String() != "134744072" {
+		t.Errorf("IPv4ToDecimal = %s", n)
+	}
+	if ip, _ := DecimalToIPv4(n); ip != "8.8.8.8" {
+		t.Errorf("DecimalToIPv4 = %s", ip)
+	}
+
+	n, _ = IPv6ToDecimal("2001:db8::1")
+	if n.String() != "42540766411282592856903984951653826561" {
+		t.Errorf("IPv6ToDecimal = %s", n)
+	}
+	if ip, _ := DecimalToIPv6(n); ip != "2001:db8::1" {
+		t.Errorf("DecimalToIPv6 = %s", ip)
+	}
+
+	if _, err := DecimalToIPv4(big.NewInt(4294967296)); err == nil {
+		t.Errorf("DecimalToIPv4 out of range should fail")
+	}
+}
+
+func TestIPIterator(t *testing.T) {
+	it, _ := NewIPv4Iterator("10.0.0.254", "10.0.1.2")
+	if it.Count().Int64() != 5 {
+		t.Errorf("Count = %s, want 5", it.Count())
+	}
+
+	it.Skip(2)
+	var got []string
+	for ip, ok := it.Next(); ok; ip, ok = it.Next() {
+		got = append(got, ip)
+	}
+	if want := []string{"10.0.1.0", "10.0.1.1", "10.0.1.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("iterated %v, want %v", got, want)
+	}
+
+	// the iterator stops at the end of the address space without wrapping around
+	it, _ = NewIPv6Iterator("ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
+	if it.Count().String() != "2" {
+		t.Errorf("Count = %s, want 2", it.Count())
+	}
+	it.Skip(5)
+	if _, ok := it.Next(); ok {
+		t.Errorf("Next after skipping past the end should be exhausted")
+	}
+
+	if _, err := NewIPv4Iterator("10.0.0.2", "10.0.0.1"); err == nil {
+		t.Errorf("NewIPv4Iterator with a reversed range should fail")
+	}
+	if _, err := NewIPv6Iterator("::", "8.8.8.8"); err == nil {
+		t.Errorf("NewIPv6Iterator with mixed families should fail")
+	}
+}
+
+// checks that the CIDRs exactly cover the range from start to end with the fewest blocks.
+func checkCover(t *testing.T, cidrs []string, start netip.Addr, end netip.Addr) {
+	t.Helper()
+
+	width := start.BitLen()
+	next := uint128FromAddr(start)
+	var prev netip.Prefix
+
+	for i, cidr := range cidrs {
+		p, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			t.Fatalf("invalid CIDR %q: %v", cidr, err)
+		}
+		if p.Masked() != p {
+			t.Fatalf("CIDR %q is not aligned", cidr)
+		}
+		if uint128FromAddr(p.Addr()) != next {
+			t.Fatalf("CIDR %q does not follow the previous block", cidr)
+		}
+		// two equal sized neighbours which share a parent block should have been merged
+		if i > 0 && prev.Bits() == p.Bits() && p.Bits() > 0 {
+			parent, _ := prev.Addr().Prefix(p.Bits() - 1)
+			if parent.Addr() == prev.Addr() {
+				t.Fatalf("CIDRs %q and %q are not minimal", prev, cidr)
+			}
+		}
+
+		last := uint128FromAddr(p.Addr()).Or(lowMask(width - p.Bits()))
+		if i == len(cidrs)-1 {
+			if last != uint128FromAddr(end) {
+				t.Fatalf("CIDRs end at %s, want %s", last.Addr(width == 128), end)
+			}
+		}
+		next, _ = last.Add(uint128{0, 1})
+		prev = p
+	}
+
+	if len(cidrs) == 0 {
+		t.Fatalf("no CIDRs for %s-%s", start, end)
+	}
+}
+
+func FuzzIPv4ToCIDR(f *testing.F) {
+	f.Add(uint32(0), uint32(0xffffffff))
+	f.Add(uint32(0x0a000001), uint32(0x0a000006))
+	f.Add(uint32(0xffffffff), uint32(0xffffffff))
+
+	f.Fuzz(func(t *testing.T, a uint32, b uint32) {
+		if a > b {
+			a, b = b, a
+		}
+		start := uint128{0, uint64(a)}.Addr(false)
+		end := uint128{0, uint64(b)}.Addr(false)
+
+		cidrs, err := IPv4ToCIDR(start.String(), end.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkCover(t, cidrs, start, end)
+	})
+}
+
+func FuzzIPv6ToCIDR(f *testing.F) {
+	f.Add(uint64(0), uint64(0), ^uint64(0), ^uint64(0))
+	f.Add(uint64(0x20010db800000000), uint64(1), uint64(0x20010db8ffff1234), uint64(0x5678fffffffffffe))
+
+	f.Fuzz(func(t *testing.T, ahi uint64, alo uint64, bhi uint64, blo uint64) {
+		a, b := uint128{ahi, alo}, uint128{bhi, blo}
+		if a.Cmp(b) > 0 {
+			a, b = b, a
+		}
+		start, end := a.Addr(true), b.Addr(true)
+		// a range of IPv4-mapped addresses is treated as IPv4
+		if start.Is4In6() && end.Is4In6() {
+			return
+		}
+
+		cidrs, err := IPv6ToCIDR(start.String(), end.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkCover(t, cidrs, start, end)
+	})
+}
+
+func FuzzCIDRToIPv4RoundTrip(f *testing.F) {
+	f.Add(uint32(0x0a7b2d43), uint8(19))
+	f.Add(uint32(0), uint8(0))
+	f.Add(uint32(0xffffffff), uint8(32))
+
+	f.Fuzz(func(t *testing.T, a uint32, bits uint8) {
+		bits = bits % 33
+		addr := uint128{0, uint64(a)}.Addr(false)
+		cidr := fmt.Sprintf("%s/%d", addr, bits)
+
+		rng, err := CIDRToIPv4(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cidrs, err := IPv4ToCIDR(rng[0], rng[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := netip.PrefixFrom(addr, int(bits)).Masked().String()
+		if len(cidrs) != 1 || cidrs[0] != want {
+			t.Fatalf("range2cidr(cidr2range(%s)) = %v, want %s", cidr, cidrs, want)
+		}
+	})
+}
+
+func FuzzCIDRToIPv6RoundTrip(f *testing.F) {
+	f.Add(uint64(0x20010db8abcd1234), uint64(0), uint8(61))
+	f.Add(uint64(0x20010db800000000), uint64(1), uint8(128))
+	f.Add(uint64(0), uint64(0), uint8(0))
+
+	f.Fuzz(func(t *testing.T, hi uint64, lo uint64, bits uint8) {
+		bits = bits % 129
+		addr := uint128{hi, lo}.Addr(true)
+		if addr.Is4In6() {
+			return
+		}
+		cidr := fmt.Sprintf("%s/%d", addr, bits)
+
+		rng, err := CIDRToIPv6(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cidrs, err := IPv6ToCIDR(rng[0], rng[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := netip.PrefixFrom(addr, int(bits)).Masked().String()
+		if len(cidrs) != 1 || cidrs[0] != want {
+			t.Fatalf("range2cidr(cidr2range(%s)) = %v, want %s", cidr, cidrs, want)
+		}
+	})
+}
+
+func BenchmarkCIDRToIPv4(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		CIDRToIPv4("10.123.45.67/19")
+	}
+}
+
+func BenchmarkCIDRToIPv6(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		CIDRToIPv6("2001:db8:abcd:1234::/61")
+	}
+}
+
+func BenchmarkIPv4ToCIDR(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		IPv4ToCIDR("10.0.0.1", "10.200.100.254")
+	}
+}
+
+func BenchmarkIPv6ToCIDR(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		IPv6ToCIDR("2001:db8::1", "2001:db8:ffff:1234:5678::fffe")
+	}
+}
+
+func BenchmarkSplitCIDRIPv6(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		SplitCIDR("2001:db8::/48", "56")
+	}
+}
+
+func BenchmarkIPIterator(b *testing.B) {
+	it, _ := NewIPv6Iterator("2001:db8::", "2001:db8::ffff:ffff:ffff:ffff")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		it.Next()
+	}
+}
+
+func BenchmarkRangeToPrefixes(b *testing.B) {
+	start := uint128{0x20010db800000000, 1}
+	end := uint128{0x20010db8ffff1234, 0x5678fffffffffffe}
+	for i := 0; i < b.N; i++ {
+		rangeToPrefixes(start, end, 128)
+	}
+}
@@ -11,6 +11,10 @@ import (
 	"strings"
 )
 
+// API endpoints, overridden in tests
+var apiURL string = "https://api.ip2location.io"
+var myIPURL string = "https://ip2location.io/get-ip.json"
+
 type Response struct {
 	IP string `json:"IP"`
 }
@@ -25,8 +29,7 @@ type IPGeolocationError struct {
 }
 
 func MyPublicIP() string {
-	myUrl := "https://ip2location.io/get-ip.json"
-	res, err := http.Get(myUrl)
+	res, err := http.Get(myIPURL)
 
 	if err != nil {
 		return ""
@@ -45,12 +48,40 @@ func PrettyString(str string) (string, error) {
 	return prettyJSON.String(), nil
 }
 
+// JSONToMap decodes the JSON lookup result into a map
+func JSONToMap(str string) (map[string]interface{}, error) {
+	var res map[string]interface{}
+	if err := json.Unmarshal([]byte(str), &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// returns the JSON lookup result with the field added last, or unchanged if it is not a JSON object.
+func addJSONField(str string, key string, value interface{}) string {
+	byteValue, err := json.Marshal(value)
+	if err != nil {
+		return str
+	}
+
+	str = strings.TrimSpace(str)
+	if !strings.HasSuffix(str, "}") {
+		return str
+	}
+
+	body := strings.TrimSpace(str[:len(str)-1])
+	if !strings.HasSuffix(body, "{") {
+		body += ","
+	}
+	return body + strconv.Quote(key) + ":" + string(byteValue) + "}"
+}
+
 // LookUpJSON will return a JSON based on the queried IP address
 func LookUpJSON(ip string, lang string) (string, error) {
 	var res string
 	var ex IPGeolocationError
 
-	myUrl := "https://api.ip2location.io?ip=" + url.QueryEscape(ip) + "&source=sdk-cli-iplio&source_version=" + version
+	myUrl := apiURL + "?ip=" + url.QueryEscape(ip) + "&source=sdk-cli-iplio&source_version=" + version
 
 	if strings.TrimSpace(apiKey) != "" {
 		myUrl = myUrl + "&key=" + url.QueryEscape(apiKey) + "&lang=" + url.QueryEscape(lang)
@@ -100,7 +131,7 @@ func LookUpMap(ip string, lang string) (map[string]interface{}, error) {
 	var res map[string]interface{}
 	var ex IPGeolocationError
 
-	myUrl := "https://api.ip2location.io?ip=" + url.QueryEscape(ip) + "&source=sdk-cli-iplio&source_version=" + version
+	myUrl := apiURL + "?ip=" + url.QueryEscape(ip) + "&source=sdk-cli-iplio&source_version=" + version
 
 	if strings.TrimSpace(apiKey) != "" {
 		myUrl = myUrl + "&key=" + url.QueryEscape(apiKey) + "&lang=" + url.QueryEscape(lang)
@@ -148,3 +179,33 @@ func LookUpMap(ip string, lang string) (map[string]interface{}, error) {
 
 	return res, errors.New("Error HTTP " + strconv.Itoa(int(resp.StatusCode)))
 }
+
+// lookUpResult returns the JSON lookup result from the --db database if used, merged with the API result
+// for --hybrid, or else from the API.
+func lookUpResult(ip string) (string, error) {
+	if lookupDB == "" {
+		return LookUpJSON(ip, myLanguage)
+	}
+
+	db, err := openLookupDB()
+	if err != nil {
+		return "", err
+	}
+	if lookupHybrid {
+		return HybridLookUp(db, ip, hybridFields, hybridMaxAge)
+	}
+	return db.LookUp(ip)
+}
+
+// lookUpResultMap returns the lookup result of lookUpResult inside a map.
+func lookUpResultMap(ip string) (map[string]interface{}, error) {
+	if lookupDB == "" {
+		return LookUpMap(ip, myLanguage)
+	}
+
+	res, err := lookUpResult(ip)
+	if err != nil {
+		return nil, err
+	}
+	return JSONToMap(res)
+}
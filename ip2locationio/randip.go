@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"os"
+	"strconv"
+	"time"
+)
+
+// the address spaces to generate from without --within, where IPv6 is limited to the global unicast addresses
+const randSpace4 = "0.0.0.0/0"
+const randSpace6 = "2000::/3"
+
+// RandSpace returns the ranges to generate random IP addresses from, which are the CIDR or range within
+// or the default IPv4 or IPv6 address space, without the special-purpose address blocks if publicOnly is true.
+func RandSpace(within string, ipv6 bool, publicOnly bool) ([]ipRange, error) {
+	space := within
+	if space == "" && ipv6 {
+		space = randSpace6
+	} else if space == "" {
+		space = randSpace4
+	}
+
+	r, err := ParseIPRange(space)
+	if err != nil {
+		return nil, err
+	} else if ipv6 && !r.IPv6 {
+		return nil, errors.New("Not an IPv6 CIDR or range: " + within)
+	}
+
+	ranges := []ipRange{r}
+	if publicOnly {
+		var special []ipRange
+		for _, s := range specialRanges {
+			p := netip.MustParsePrefix(s.CIDR)
+			start := uint128FromAddr(p.Addr())
+			special = append(special, ipRange{start, start.Or(lowMask(p.Addr().BitLen() - p.Bits())), p.Addr().Is6()})
+		}
+
+		ranges = excludeRanges(ranges, mergeRanges(special))
+		if len(ranges) == 0 {
+			return nil, errors.New("No public IP address in " + space + ".")
+		}
+	}
+
+	return ranges, nil
+}
+
+// RandIP returns an IP address picked uniformly from the ranges.
+func RandIP(rng *rand.Rand, ranges []ipRange) string {
+	one := uint128{0, 1}
+
+	// a total of zero means the whole IPv6 address space
+	var total uint128
+	for _, r := range ranges {
+		size, _ := r.End.Sub(r.Start).Add(one)
+		total, _ = total.Add(size)
+	}
+
+	k := randUint128(rng, total)
+	for _, r := range ranges {
+		size, _ := r.End.Sub(r.Start).Add(one)
+		if size == (uint128{}) || k.Cmp(size) < 0 {
+			ip, _ := r.Start.Add(k)
+			return ip.Addr(r.IPv6).String()
+		}
+		k = k.Sub(size)
+	}
+
+	return ""
+}
+
+// returns a random number below n, or any number if n is zero.
+func randUint128(rng *rand.Rand, n uint128) uint128 {
+	mask := lowMask(128)
+	if n != (uint128{}) {
+		mask = lowMask(128 - n.Sub(uint128{0, 1}).LeadingZeros())
+	}
+
+	// rejection sampling keeps the numbers uniform, with less than 2 tries expected
+	for {
+		u := uint128{rng.Uint64(), rng.Uint64()}.And(mask)
+		if n == (uint128{}) || u.Cmp(n) < 0 {
+			return u
+		}
+	}
+}
+
+func PrintRandIP(args []string) int {
+	seed := time.Now().UnixNano()
+	if randSeed != "" {
+		var err error
+		if seed, err = strconv.ParseInt(randSeed, 10, 64); err != nil {
+			fmt.Println("Invalid seed.")
+			return 1
+		}
+	}
+
+	ranges, err := RandSpace(randWithin, randIPv6, randPublicOnly)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	w := bufio.NewWriter(os.Stdout)
+	for i := uint64(0); i < randCount; i++ {
+		fmt.Fprintln(w, RandIP(rng, ranges))
+	}
+	w.Flush()
+	return 0
+}
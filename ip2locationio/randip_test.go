@@ -0,0 +1,76 @@
+package main
+
+import (
+	"math/rand"
+	"net/netip"
+	"testing"
+)
+
+func TestRandIP(t *testing.T) {
+	tests := []struct {
+		within     string
+		ipv6       bool
+		publicOnly bool
+		space      string
+	}{
+		{"", false, false, "0.0.0.0/0"},
+		{"", false, true, "0.0.0.0/0"},
+		{"", true, false, "2000::/3"},
+		{"", true, true, "2000::/3"},
+		{"192.0.2.0/30", false, false, "192.0.2.0/30"},
+		{"10.0.0.250-10.0.1.5", false, false, "10.0.0.0/23"},
+		{"::/0", false, false, "::/0"},
+		{"100.0.0.0/8", false, true, "100.0.0.0/8"},
+	}
+
+	for _, tt := range tests {
+		ranges, err := RandSpace(tt.within, tt.ipv6, tt.publicOnly)
+		if err != nil {
+			t.Fatalf("RandSpace(%q, %t, %t) error = %v", tt.within, tt.ipv6, tt.publicOnly, err)
+		}
+
+		space := netip.MustParsePrefix(tt.space)
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 1000; i++ {
+			ip := RandIP(rng, ranges)
+			addr, err := netip.ParseAddr(ip)
+			if err != nil || !space.Contains(addr) || (tt.publicOnly && isSpecial(addr)) {
+				t.Fatalf("RandIP(%q, %t, %t) = %q", tt.within, tt.ipv6, tt.publicOnly, ip)
+			}
+		}
+	}
+
+	for _, within := range []string{"10.0.0.0/8", "x", "10.0.0.0/33"} {
+		if _, err := RandSpace(within, true, false); err == nil {
+			t.Errorf("RandSpace(%q, true, false) error = nil, want error", within)
+		}
+	}
+	if _, err := RandSpace("192.168.0.0/16", false, true); err == nil {
+		t.Errorf("RandSpace(192.168.0.0/16, false, true) error = nil, want error")
+	}
+}
+
+func TestRandIPUniform(t *testing.T) {
+	ranges, _ := RandSpace("192.0.2.0/24", false, false)
+	rng := rand.New(rand.NewSource(1))
+	seen := make(map[string]bool)
+
+	for i := 0; i < 10000; i++ {
+		seen[RandIP(rng, ranges)] = true
+	}
+	if len(seen) != 256 || !seen["192.0.2.0"] || !seen["192.0.2.255"] {
+		t.Errorf("RandIP generated %d of 256 addresses", len(seen))
+	}
+}
+
+func TestRandIPSeed(t *testing.T) {
+	ranges, _ := RandSpace("", true, true)
+	a := rand.New(rand.NewSource(42))
+	b := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 100; i++ {
+		if x, y := RandIP(a, ranges), RandIP(b, ranges); x != y {
+			t.Fatalf("RandIP with the same seed = %q, %q", x, y)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// the timeout of the reverse and forward DNS lookups of an IP address
+const rdnsTimeout = 5 * time.Second
+
+// The RDNS struct stores the PTR names of an IP address and whether any of them resolves back to it.
+type RDNS struct {
+	Names            []string `json:"names"`
+	ForwardConfirmed bool     `json:"forward_confirmed"`
+	Error            string   `json:"error,omitempty"`
+}
+
+// returns the resolver for the --resolver address, or the system resolver if none.
+func newResolver(address string) *net.Resolver {
+	if address == "" {
+		return net.DefaultResolver
+	}
+
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(strings.Trim(address, "[]"), "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// ReverseDNS returns the PTR names of the IP address, and whether any of them is forward-confirmed,
+// i.e. resolves back to the IP address.
+func ReverseDNS(r *net.Resolver, ip string) RDNS {
+	res := RDNS{Names: []string{}}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		res.Error = "Not a valid IP address."
+		return res
+	}
+	addr = addr.Unmap()
+
+	ctx, cancel := context.WithTimeout(context.Background(), rdnsTimeout)
+	defer cancel()
+
+	names, err := r.LookupAddr(ctx, addr.String())
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); !ok || !dnsErr.IsNotFound {
+			res.Error = err.Error()
+		}
+		return res
+	}
+
+	for _, name := range names {
+		res.Names = append(res.Names, strings.TrimSuffix(name, "."))
+
+		if res.ForwardConfirmed {
+			continue
+		}
+		addrs, err := r.LookupIPAddr(ctx, name)
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if fwd, ok := netip.AddrFromSlice(a.IP); ok && fwd.Unmap() == addr {
+				res.ForwardConfirmed = true
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// returns the JSON lookup result with the reverse DNS of the IP address added as the last field.
+func addRDNSJSON(str string, ip string) string {
+	return addJSONField(str, "rdns", ReverseDNS(newResolver(rdnsResolver), ip))
+}
+
+// adds the reverse DNS of the IP address to the lookup result.
+func addRDNSMap(ipl map[string]interface{}, ip string) {
+	byteValue, err := json.Marshal(ReverseDNS(newResolver(rdnsResolver), ip))
+	if err != nil {
+		return
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(byteValue, &v); err == nil {
+		ipl["rdns"] = v
+	}
+}
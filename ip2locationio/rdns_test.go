@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// the records of the DNS stand-in, by lowercase name and type
+var dnsRecords = map[string]map[uint16][]string{
+	"1.2.0.192.in-addr.arpa.": {12: {"host.example.", "alias.example."}},
+	"2.2.0.192.in-addr.arpa.": {12: {"spoof.example."}},
+	"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.": {12: {"v6.example."}},
+	"host.example.":         {1: {"192.0.2.1"}},
+	"alias.example.":        {1: {"192.0.2.1"}},
+	"spoof.example.":        {1: {"198.51.100.1"}},
+	"8.8.8.8.in-addr.arpa.": {12: {"dns.google."}},
+	"dns.google.":           {1: {"8.8.4.4", "8.8.8.8"}},
+	"v6.example.":           {28: {"2001:db8::1"}},
+}
+
+// starts a DNS server on a local UDP port answering from dnsRecords and returns its address.
+func startDNSServer(t testing.TB) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if res := dnsAnswer(buf[:n]); res != nil {
+				conn.WriteTo(res, addr)
+			}
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+// returns the response to the DNS query, answering the first question only.
+func dnsAnswer(query []byte) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	var labels []string
+	i := 12
+	for i < len(query) && query[i] != 0 {
+		l := int(query[i])
+		if i+1+l > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[i+1:i+1+l]))
+		i += 1 + l
+	}
+	if i+5 > len(query) {
+		return nil
+	}
+	name := strings.ToLower(strings.Join(labels, ".")) + "."
+	qtype := binary.BigEndian.Uint16(query[i+1 : i+3])
+	question := query[12 : i+5]
+
+	res := append([]byte{}, query[:2]...)
+	records, found := dnsRecords[name]
+	if found {
+		// response, authoritative, recursion desired and available
+		res = append(res, 0x85, 0x80)
+	} else {
+		// name error
+		res = append(res, 0x85, 0x83)
+	}
+	res = appendUint16(res, 1)
+	res = appendUint16(res, uint16(len(records[qtype])))
+	res = append(res, 0, 0, 0, 0)
+	res = append(res, question...)
+
+	for _, value := range records[qtype] {
+		var data []byte
+		if qtype == 12 {
+			for _, label := range strings.Split(strings.TrimSuffix(value, "."), ".") {
+				data = append(data, byte(len(label)))
+				data = append(data, label...)
+			}
+			data = append(data, 0)
+		} else {
+			data = netip.MustParseAddr(value).AsSlice()
+		}
+
+		// the name is a pointer to the question
+		res = append(res, 0xc0, 12)
+		res = appendUint16(res, qtype)
+		res = appendUint16(res, 1)
+		res = append(res, 0, 0, 0, 60)
+		res = appendUint16(res, uint16(len(data)))
+		res = append(res, data...)
+	}
+
+	return res
+}
+
+// appends the number in big-endian order.
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func TestReverseDNS(t *testing.T) {
+	r := newResolver(startDNSServer(t))
+
+	tests := []struct {
+		ip   string
+		want RDNS
+	}{
+		{"192.0.2.1", RDNS{[]string{"host.example", "alias.example"}, true, ""}},
+		{"::ffff:192.0.2.1", RDNS{[]string{"host.example", "alias.example"}, true, ""}},
+		{"192.0.2.2", RDNS{[]string{"spoof.example"}, false, ""}},
+		{"2001:db8::1", RDNS{[]string{"v6.example"}, true, ""}},
+		{"192.0.2.3", RDNS{[]string{}, false, ""}},
+	}
+
+	for _, tt := range tests {
+		if got := ReverseDNS(r, tt.ip); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReverseDNS(%q) = %+v, want %+v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestAddRDNSJSON(t *testing.T) {
+	rdnsResolver = startDNSServer(t)
+	defer func() { rdnsResolver = "" }()
+
+	tests := map[string]string{
+		`{"ip":"192.0.2.1","country_code":"-"}`: `{"ip":"192.0.2.1","country_code":"-","rdns":{"names":["host.example","alias.example"],"forward_confirmed":true}}`,
+		"{\n}\n":                                `{"rdns":{"names":["host.example","alias.example"],"forward_confirmed":true}}`,
+	}
+
+	for str, want := range tests {
+		if got := addRDNSJSON(str, "192.0.2.1"); got != want {
+			t.Errorf("addRDNSJSON(%q) = %s, want %s", str, got, want)
+		}
+	}
+}
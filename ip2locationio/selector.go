@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The Selector struct stores a parsed -f field selector such as
+// "continent.hemisphere[0]", "country.*" or "country_code as cc".
+type Selector struct {
+	Text     string
+	Alias    string
+	Segments []string // map keys, "*" wildcards or "[n]"/"[*]" array indices
+}
+
+// The Column struct stores a concrete output column after wildcard expansion.
+type Column struct {
+	Header   string
+	Segments []string
+}
+
+var aliasRegex = regexp.MustCompile(`(?i)^(.+?)\s+as\s+(\S+)$`)
+
+// ParseSelectors parses the comma-separated field selectors.
+func ParseSelectors(str string) ([]Selector, error) {
+	var sels []Selector
+
+	for _, item := range strings.Split(str, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			return nil, errors.New("Invalid field selector: empty field name.")
+		}
+
+		sel := Selector{Text: item}
+		if m := aliasRegex.FindStringSubmatch(item); m != nil {
+			sel.Text = strings.TrimSpace(m[1])
+			sel.Alias = m[2]
+		}
+
+		segments, err := parsePath(sel.Text)
+		if err != nil {
+			return nil, err
+		}
+		sel.Segments = segments
+
+		if sel.Alias != "" && sel.HasWildcard() {
+			return nil, errors.New("Invalid field selector: alias cannot be used with wildcard in '" + item + "'.")
+		}
+
+		sels = append(sels, sel)
+	}
+
+	return sels, nil
+}
+
+// splits the field path into map keys and array indices.
+func parsePath(path string) ([]string, error) {
+	var segments []string
+	invalid := errors.New("Invalid field selector: '" + path + "'.")
+
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			if i == 0 || i+1 == len(path) || path[i+1] == '.' || path[i+1] == '[' {
+				return nil, invalid
+			}
+			i++
+		case '[':
+			j := strings.IndexByte(path[i:], ']')
+			if j == -1 {
+				return nil, invalid
+			}
+			index := path[i+1 : i+j]
+			if index != "*" {
+				if _, err := strconv.Atoi(index); err != nil {
+					return nil, invalid
+				}
+			}
+			segments = append(segments, "["+index+"]")
+			i = i + j + 1
+		default:
+			j := strings.IndexAny(path[i:], ".[")
+			if j == -1 {
+				j = len(path) - i
+			}
+			segments = append(segments, path[i:i+j])
+			i = i + j
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, invalid
+	}
+
+	return segments, nil
+}
+
+// HasWildcard returns true if the selector contains a wildcard segment.
+func (s Selector) HasWildcard() bool {
+	for _, seg := range s.Segments {
+		if seg == "*" || seg == "[*]" {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandSelectors returns the output columns, expanding the wildcards
+// using the keys and array items present in the lookup result.
+func ExpandSelectors(sels []Selector, ipl map[string]interface{}) []Column {
+	var cols []Column
+
+	for _, sel := range sels {
+		if !sel.HasWildcard() {
+			header := sel.Text
+			if sel.Alias != "" {
+				header = sel.Alias
+			}
+			cols = append(cols, Column{Header: header, Segments: sel.Segments})
+			continue
+		}
+
+		for _, segments := range expandPath(ipl, sel.Segments, nil) {
+			cols = append(cols, Column{Header: joinPath(segments), Segments: segments})
+		}
+	}
+
+	return cols
+}
+
+// returns the concrete paths matching the segments with wildcards.
+func expandPath(v interface{}, segments []string, prefix []string) [][]string {
+	if len(segments) == 0 {
+		return [][]string{prefix}
+	}
+
+	var res [][]string
+	seg := segments[0]
+
+	switch {
+	case seg == "*":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			res = append(res, expandPath(m[k], segments[1:], appendPath(prefix, k))...)
+		}
+	case seg == "[*]":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil
+		}
+		for i := range arr {
+			res = append(res, expandPath(arr[i], segments[1:], appendPath(prefix, "["+strconv.Itoa(i)+"]"))...)
+		}
+	default:
+		// skip the paths which do not exist under the wildcard
+		next, ok := resolvePath(v, []string{seg})
+		if !ok {
+			if m, isMap := v.(map[string]interface{}); !isMap || !hasKey(m, seg) {
+				return nil
+			}
+		}
+		res = expandPath(next, segments[1:], appendPath(prefix, seg))
+	}
+
+	return res
+}
+
+// returns a copy of the path with the segment appended.
+func appendPath(path []string, seg string) []string {
+	res := make([]string, len(path), len(path)+1)
+	copy(res, path)
+	return append(res, seg)
+}
+
+// joins the segments back into a field path.
+func joinPath(segments []string) string {
+	var sb strings.Builder
+	for i, seg := range segments {
+		if i > 0 && !strings.HasPrefix(seg, "[") {
+			sb.WriteString(".")
+		}
+		sb.WriteString(seg)
+	}
+	return sb.String()
+}
+
+// returns the value at the path, or false if it is missing or null.
+func resolvePath(v interface{}, segments []string) (interface{}, bool) {
+	for _, seg := range segments {
+		if v == nil {
+			return nil, false
+		}
+
+		switch t := v.(type) {
+		case map[string]interface{}:
+			next, exists := t[seg]
+			if !exists {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			index, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(seg, "["), "]"))
+			if err != nil {
+				return nil, false
+			}
+			if index < 0 {
+				index = len(t) + index
+			}
+			if index < 0 || index >= len(t) {
+				return nil, false
+			}
+			v = t[index]
+		default:
+			return nil, false
+		}
+	}
+
+	if v == nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// ResolveField traverses the lookup result using the field path,
+// e.g. "country.currency.code" or "continent.hemisphere[0]".
+func ResolveField(ipl map[string]interface{}, field string) (interface{}, bool) {
+	segments, err := parsePath(field)
+	if err != nil {
+		return nil, false
+	}
+	return resolvePath(ipl, segments)
+}
+
+// returns the last map key in the path, used for formatting the value.
+func lastKey(segments []string) string {
+	for i := len(segments) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(segments[i], "[") {
+			return segments[i]
+		}
+	}
+	return ""
+}
+
+// returns true if the map contains the key, even if the value is null.
+func hasKey(m map[string]interface{}, key string) bool {
+	_, exists := m[key]
+	return exists
+}
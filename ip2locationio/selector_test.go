@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectors(t *testing.T) {
+	ipl := loadResult(t, "8.8.8.8")
+
+	tests := []struct {
+		fields  string
+		headers []string
+		values  []string
+	}{
+		{"country_code,latitude,elevation", []string{"country_code", "latitude", "elevation"}, []string{`"US"`, "37.405992", "32"}},
+		{"country_code as cc, isp AS provider", []string{"cc", "provider"}, []string{`"US"`, `"Google LLC"`}},
+		{"continent.hemisphere[1],continent.hemisphere", []string{"continent.hemisphere[1]", "continent.hemisphere"}, []string{`"west"`, "[north west]"}},
+		{"country.currency.*", []string{"country.currency.code", "country.currency.name", "country.currency.symbol"}, []string{`"USD"`, `"United States Dollar"`, `"$"`}},
+		{"continent.hemisphere[*]", []string{"continent.hemisphere[0]", "continent.hemisphere[1]"}, []string{`"north"`, `"west"`}},
+		{"*.translation.lang", []string{"city.translation.lang", "continent.translation.lang", "country.translation.lang", "region.translation.lang"}, []string{"", `"es"`, `"es"`, `"es"`}},
+		{"missing,isp.name,continent.hemisphere[5],proxy.is_vpn", []string{"missing", "isp.name", "continent.hemisphere[5]", "proxy.is_vpn"}, []string{"", "", "", "false"}},
+	}
+
+	for _, tt := range tests {
+		sels, err := ParseSelectors(tt.fields)
+		if err != nil {
+			t.Errorf("ParseSelectors(%q) error: %v", tt.fields, err)
+			continue
+		}
+
+		var headers, values []string
+		for _, col := range ExpandSelectors(sels, ipl) {
+			v, _ := resolvePath(ipl, col.Segments)
+			headers = append(headers, col.Header)
+			values = append(values, FormatField(lastKey(col.Segments), v))
+		}
+
+		if !reflect.DeepEqual(headers, tt.headers) || !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("%q = %v %v, want %v %v", tt.fields, headers, values, tt.headers, tt.values)
+		}
+	}
+}
+
+func TestSelectorErrors(t *testing.T) {
+	for _, fields := range []string{"a..b", ".a", "a.", "a[1", "a[x]", "a,,b", "country.* as c"} {
+		if _, err := ParseSelectors(fields); err == nil {
+			t.Errorf("ParseSelectors(%q) should fail", fields)
+		}
+	}
+}
@@ -1,17 +1,36 @@
 package main
 
 import (
-	"encoding/binary"
 	"errors"
-	"math"
 	"math/big"
+	"math/bits"
 	"net"
+	"net/netip"
 	"strconv"
 )
 
+// SplitCIDR returns the subnets with the split prefix length in the CIDR.
 func SplitCIDR(cidr string, split string) ([]string, error) {
+	it, err := NewSplitIterator(cidr, split)
+	if err != nil {
+		return nil, err
+	}
+
 	var res []string
-	ip, _, err := net.ParseCIDR(cidr)
+	for {
+		subnet, ok := it.Next()
+		if !ok {
+			break
+		}
+		res = append(res, subnet)
+	}
+
+	return res, nil
+}
+
+// NewSplitIterator returns the iterator of the subnets with the split prefix length in the CIDR.
+func NewSplitIterator(cidr string, split string) (*SubnetIterator, error) {
+	_, network, err := net.ParseCIDR(cidr)
 	if err != nil {
 		return nil, err
 	}
@@ -21,204 +40,163 @@ func SplitCIDR(cidr string, split string) ([]string, error) {
 		return nil, err
 	}
 
-	if ip.To4() != nil {
-		ipsubnet, err := GetIPv4Subnet(cidr)
-		if err != nil {
-			return nil, err
-		}
-
-		subs, err := SplitCIDRIPv4(ipsubnet, toSplit)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, s := range subs {
-			netBitCntStr := strconv.Itoa(int(s.NetBitCnt))
-			ipStr := net.IPv4(byte(s.LoIP>>24), byte(s.LoIP>>16), byte(s.LoIP>>8), byte(s.LoIP)).String()
+	bits, width := network.Mask.Size()
+	if toSplit < bits || toSplit > width {
+		return nil, errors.New("Invalid split.")
+	}
 
-			res = append(res, ipStr+"/"+netBitCntStr)
-		}
-	} else {
-		ipsubnet, err := GetIPv6Subnet(cidr)
-		if err != nil {
-			return nil, err
-		}
-		subs, err := SplitCIDRIPv6(ipsubnet, toSplit)
-		if err != nil {
-			return nil, err
-		}
-		for _, s := range subs {
-			netBitCntStr := strconv.Itoa(int(s.NetBitCnt))
-			b := make([]byte, 16)
-			binary.BigEndian.PutUint64(b[:8], s.LoIP.Hi)
-			binary.BigEndian.PutUint64(b[8:], s.LoIP.Lo)
-			ipStr := net.IP(b).String()
-			res = append(res, ipStr+"/"+netBitCntStr)
-		}
+	// IPv4-mapped CIDRs such as ::ffff:0:0/96 stay IPv6
+	ip := network.IP.To16()
+	if width == 32 {
+		ip = network.IP.To4()
 	}
+	addr, _ := netip.AddrFromSlice(ip)
 
-	return res, nil
+	return NewSubnetIterator([]prefix{{uint128FromAddr(addr), bits}}, width == 128, toSplit), nil
 }
 
-type uint128 struct {
-	Hi uint64
-	Lo uint64
+// The SubnetIterator struct generates the subnets with a prefix length in a list of CIDR blocks
+// in order, without keeping them in memory.
+type SubnetIterator struct {
+	blocks []prefix
+	ipv6   bool
+	size   int
+	next   uint128
 }
 
-type IPv4Subnet struct {
-	NetBitCnt  uint32
-	NetMask    uint32
-	HostBitCnt uint32
-	HostMask   uint32
-	LoIP       uint32
-	HiIP       uint32
+// NewSubnetIterator returns the iterator of the subnets with the prefix length in the blocks,
+// where the blocks smaller than the subnets are skipped.
+func NewSubnetIterator(blocks []prefix, ipv6 bool, size int) *SubnetIterator {
+	it := &SubnetIterator{ipv6: ipv6, size: size}
+	for _, b := range blocks {
+		if b.Bits <= size {
+			it.blocks = append(it.blocks, b)
+		}
+	}
+	if len(it.blocks) > 0 {
+		it.next = it.blocks[0].Start
+	}
+	return it
 }
 
-type IPv6Subnet struct {
-	NetBitCnt  uint32
-	NetMask    uint128
-	HostBitCnt uint32
-	HostMask   uint128
-	LoIP       uint128
-	HiIP       uint128
+// returns the address width of the subnets.
+func (it *SubnetIterator) width() int {
+	if it.ipv6 {
+		return 128
+	}
+	return 32
 }
 
-func NetAndHostMasksIPv4(size uint32) (uint32, uint32) {
-	if size > 32 {
-		size = 32
-	}
+// Count returns the number of subnets left.
+func (it *SubnetIterator) Count() *big.Int {
+	count := new(big.Int)
 
-	var mask uint32 = 0
-	for i := uint32(0); i < size; i++ {
-		mask += 1 << (32 - (i + 1))
+	for i, b := range it.blocks {
+		n := new(big.Int).Lsh(big.NewInt(1), uint(it.size-b.Bits))
+		if i == 0 {
+			done := it.next.Sub(b.Start).BigInt()
+			n.Sub(n, done.Rsh(done, uint(it.width()-it.size)))
+		}
+		count.Add(count, n)
 	}
 
-	return mask, ^mask
+	return count
 }
 
-func NetAndHostMasksIPv6(size uint32) (uint128, uint128) {
-	if size > 128 {
-		size = 128
+// Next returns the next subnet, or false if there are no more.
+func (it *SubnetIterator) Next() (string, bool) {
+	if len(it.blocks) == 0 {
+		return "", false
 	}
 
-	var mask uint128
-	if size > 64 {
-		mask = uint128{^uint64(0), ^uint64(0) << (128 - size)}
+	width := it.width()
+	res := it.next.Addr(it.ipv6).String() + "/" + strconv.Itoa(it.size)
+
+	last := it.next.Or(lowMask(width - it.size))
+	if b := it.blocks[0]; last == b.Start.Or(lowMask(width-b.Bits)) {
+		it.blocks = it.blocks[1:]
+		if len(it.blocks) > 0 {
+			it.next = it.blocks[0].Start
+		}
 	} else {
-		mask = uint128{^uint64(0) << (64 - size), 0}
+		it.next, _ = last.Add(uint128{0, 1})
 	}
-	maskNot := uint128{^mask.Hi, ^mask.Lo}
 
-	return mask, maskNot
+	return res, true
 }
 
-func GetIPv4Subnet(cidr string) (IPv4Subnet, error) {
-	_, network, err := net.ParseCIDR(cidr)
+// SplitPrefixForSubnets returns the prefix length to split the CIDR into at least n subnets of the same size.
+func SplitPrefixForSubnets(cidr string, n uint64) (int, error) {
+	prefixLen, width, err := cidrBits(cidr)
 	if err != nil {
-		return IPv4Subnet{}, err
+		return 0, err
+	} else if n == 0 {
+		return 0, errors.New("Invalid number of subnets.")
 	}
 
-	ones, _ := network.Mask.Size()
-	netMask, hostMask := NetAndHostMasksIPv4(uint32(ones))
-	start := binary.BigEndian.Uint32(network.IP)
-	ipsubnet := IPv4Subnet{
-		HostBitCnt: uint32(32 - ones),
-		HostMask:   hostMask,
-		NetBitCnt:  uint32(ones),
-		NetMask:    netMask,
-		LoIP:       uint32(start) & netMask,
-		HiIP:       (uint32(start) & netMask) | hostMask,
+	// the number of subnets is rounded up to a power of two
+	split := prefixLen + bits.Len64(n-1)
+	if split > width {
+		return 0, errors.New("The CIDR is too small for " + strconv.FormatUint(n, 10) + " subnets.")
 	}
 
-	return ipsubnet, nil
+	return split, nil
 }
 
-func GetIPv6Subnet(cidr string) (IPv6Subnet, error) {
-	_, network, err := net.ParseCIDR(cidr)
+// SplitPrefixForHosts returns the prefix length to split the CIDR into the smallest subnets
+// with at least the number of usable hosts.
+func SplitPrefixForHosts(cidr string, hosts uint64) (int, error) {
+	prefixLen, width, err := cidrBits(cidr)
+	if err != nil {
+		return 0, err
+	}
+
+	hostBits, err := hostBitsFor(hosts, width == 128)
 	if err != nil {
-		return IPv6Subnet{}, err
+		return 0, err
+	} else if width-hostBits < prefixLen {
+		return 0, errors.New("The CIDR is too small for " + strconv.FormatUint(hosts, 10) + " hosts.")
 	}
 
-	ones, _ := network.Mask.Size()
-	netMask, hostMask := NetAndHostMasksIPv6(uint32(ones))
-	starthi := binary.BigEndian.Uint64(network.IP[:8])
-	startlo := binary.BigEndian.Uint64(network.IP[8:])
-	start := uint128{Hi: starthi, Lo: startlo}
-	ip6subnet := IPv6Subnet{
-		HostBitCnt: uint32(128 - ones),
-		HostMask:   hostMask,
-		NetBitCnt:  uint32(ones),
-		NetMask:    netMask,
-		LoIP:       uint128{Hi: start.Hi & netMask.Hi, Lo: start.Lo & netMask.Lo},
-		HiIP:       uint128{Hi: (start.Hi & netMask.Hi) | hostMask.Hi, Lo: (start.Lo & netMask.Lo) | hostMask.Lo},
+	return width - hostBits, nil
+}
+
+// returns the prefix length and the address width of the CIDR.
+func cidrBits(cidr string) (int, int, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0, 0, err
 	}
 
-	return ip6subnet, nil
+	bits, width := network.Mask.Size()
+	return bits, width, nil
 }
 
-func SplitCIDRIPv4(s IPv4Subnet, split int) ([]IPv4Subnet, error) {
-	bitshifts := int(uint32(split) - s.NetBitCnt)
-	if bitshifts < 0 || bitshifts > 31 || int(s.NetBitCnt)+bitshifts > 32 {
-		return nil, errors.New("Invalid split.")
+// returns the smallest number of host bits giving at least the number of usable hosts,
+// where the network and broadcast addresses of IPv4 subnets larger than /31 are not usable.
+func hostBitsFor(hosts uint64, ipv6 bool) (int, error) {
+	if hosts == 0 {
+		return 0, errors.New("Invalid number of hosts.")
 	}
 
-	hostBits := (32 - s.NetBitCnt) - uint32(bitshifts)
-	netMask, hostMask := NetAndHostMasksIPv4(uint32(split))
-	ipsubnets := make([]IPv4Subnet, 1<<bitshifts)
-	for i := range ipsubnets {
-		start := uint32(s.LoIP) + uint32(i*(1<<hostBits))
-		ipsubnets[i] = IPv4Subnet{
-			HostBitCnt: uint32(32 - split),
-			HostMask:   hostMask,
-			NetBitCnt:  uint32(split),
-			LoIP:       uint32(start) & netMask,
-			HiIP:       (uint32(start) & netMask) | hostMask,
-		}
+	width := 32
+	if ipv6 {
+		width = 128
 	}
 
-	return ipsubnets, nil
-}
+	for h := 0; h <= width; h++ {
+		if h >= 64 {
+			return h, nil
+		}
 
-func SplitCIDRIPv6(s IPv6Subnet, split int) ([]IPv6Subnet, error) {
-	bitshifts := int(uint32(split) - s.NetBitCnt)
-	if bitshifts < 0 || bitshifts > 128 || int(s.NetBitCnt)+bitshifts > 128 {
-		return nil, errors.New("Invalid split. ")
-	}
-
-	hostBits := (128 - s.NetBitCnt) - uint32(bitshifts)
-	netMask, hostMask := NetAndHostMasksIPv6(uint32(split))
-	subnetCount := math.Pow(2, float64(bitshifts))
-	subnetCountBig := big.NewFloat(subnetCount)
-	hostCount := math.Pow(2, float64(hostBits))
-	hostCountbig := big.NewFloat(hostCount)
-
-	var ipsubnets []IPv6Subnet
-	for i := big.NewFloat(0); i.Cmp(subnetCountBig) < 0; i.Add(i, big.NewFloat(1)) {
-		hostCountMul := new(big.Float)
-		hostCountMul.Mul(i, hostCountbig)
-		newIP := new(big.Int)
-		b := make([]byte, 16)
-		binary.BigEndian.PutUint64(b[:8], s.LoIP.Hi)
-		binary.BigEndian.PutUint64(b[8:], s.LoIP.Lo)
-		newIP.SetBytes(b)
-		hostCountAdd := new(big.Int)
-		result := new(big.Int)
-		hostCountMul.Int(result)
-		hostCountAdd.Add(newIP, result)
-
-		ipArr := []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
-		copy(ipArr[16-len(hostCountAdd.Bytes()):], hostCountAdd.Bytes())
-		startIP := uint128{Hi: binary.BigEndian.Uint64(ipArr[:8]), Lo: binary.BigEndian.Uint64(ipArr[8:])}
-
-		subnet := IPv6Subnet{
-			HostBitCnt: uint32(128 - split),
-			HostMask:   hostMask,
-			NetBitCnt:  uint32(split),
-			LoIP:       uint128{Hi: startIP.Hi & netMask.Hi, Lo: startIP.Lo & netMask.Lo},
-			HiIP:       uint128{Hi: (startIP.Hi & netMask.Hi) | hostMask.Hi, Lo: (startIP.Lo & netMask.Lo) | hostMask.Lo},
+		usable := uint64(1) << h
+		if !ipv6 && h >= 2 {
+			usable = usable - 2
+		}
+		if usable >= hosts {
+			return h, nil
 		}
-		ipsubnets = append(ipsubnets, subnet)
 	}
 
-	return ipsubnets, nil
+	return 0, errors.New("Invalid number of hosts.")
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCIDR(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		split   string
+		want    []string
+		wantErr bool
+	}{
+		{"10.0.0.0/24", "26", []string{"10.0.0.0/26", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/26"}, false},
+		{"10.0.0.77/24", "25", []string{"10.0.0.0/25", "10.0.0.128/25"}, false},
+		{"10.0.0.0/24", "24", []string{"10.0.0.0/24"}, false},
+		{"2001:db8::/32", "34", []string{"2001:db8::/34", "2001:db8:4000::/34", "2001:db8:8000::/34", "2001:db8:c000::/34"}, false},
+		{"::/0", "1", []string{"::/1", "8000::/1"}, false},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/126", "128", []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffc/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffd/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/128", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128"}, false},
+		{"10.0.0.0/8", "7", nil, true},
+		{"10.0.0.0/8", "33", nil, true},
+		{"2001:db8::/120", "129", nil, true},
+		{"10.0.0.0/24", "x", nil, true},
+		{"x", "1", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := SplitCIDR(tt.cidr, tt.split)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitCIDR(%q, %q) = %v, %v, want %v", tt.cidr, tt.split, got, err, tt.want)
+		}
+	}
+}
+
+// checks that the subnets are exactly the parent split into blocks of the same size.
+func checkSplit(t *testing.T, parent netip.Prefix, split int, subnets []string) {
+	t.Helper()
+
+	if want := 1 << (split - parent.Bits()); len(subnets) != want {
+		t.Fatalf("split %s into /%d gave %d subnets, want %d", parent, split, len(subnets), want)
+	}
+
+	width := parent.Addr().BitLen()
+	next := uint128FromAddr(parent.Masked().Addr())
+	for _, s := range subnets {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			t.Fatalf("invalid subnet %q: %v", s, err)
+		}
+		if p.Bits() != split || uint128FromAddr(p.Addr()) != next {
+			t.Fatalf("subnet %q is not the next /%d in %s", s, split, parent)
+		}
+		next, _ = uint128FromAddr(p.Addr()).Or(lowMask(width - split)).Add(uint128{0, 1})
+	}
+
+	parentLast := uint128FromAddr(parent.Masked().Addr()).Or(lowMask(width - parent.Bits()))
+	if last, _ := parentLast.Add(uint128{0, 1}); next != last {
+		t.Fatalf("subnets do not end at the end of %s", parent)
+	}
+}
+
+func FuzzSplitCIDRIPv4(f *testing.F) {
+	f.Add(uint32(0x0a000000), uint8(8), uint8(4))
+	f.Add(uint32(0xffffffff), uint8(30), uint8(2))
+
+	f.Fuzz(func(t *testing.T, a uint32, bits uint8, extra uint8) {
+		bits = bits % 33
+		// keep the number of subnets small
+		split := int(bits) + int(extra)%(33-int(bits))%11
+		parent := netip.PrefixFrom(uint128{0, uint64(a)}.Addr(false), int(bits))
+
+		subnets, err := SplitCIDR(parent.String(), fmt.Sprint(split))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSplit(t, parent, split, subnets)
+	})
+}
+
+func FuzzSplitCIDRIPv6(f *testing.F) {
+	f.Add(uint64(0x20010db800000000), uint64(0), uint8(48), uint8(8))
+	f.Add(^uint64(0), ^uint64(0), uint8(124), uint8(4))
+	f.Add(uint64(0), uint64(0), uint8(0), uint8(1))
+
+	f.Fuzz(func(t *testing.T, hi uint64, lo uint64, bits uint8, extra uint8) {
+		bits = bits % 129
+		split := int(bits) + int(extra)%(129-int(bits))%11
+		parent := netip.PrefixFrom(uint128{hi, lo}.Addr(true), int(bits))
+		if parent.Addr().Is4In6() {
+			return
+		}
+
+		subnets, err := SplitCIDR(parent.String(), fmt.Sprint(split))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSplit(t, parent, split, subnets)
+	})
+}
+
+func TestSplitPrefix(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		subnets uint64
+		hosts   uint64
+		want    int
+		wantErr bool
+	}{
+		{"10.0.0.0/24", 1, 0, 24, false},
+		{"10.0.0.0/24", 4, 0, 26, false},
+		{"10.0.0.0/24", 6, 0, 27, false},
+		{"10.0.0.0/24", 256, 0, 32, false},
+		{"10.0.0.0/24", 257, 0, 0, true},
+		{"2001:db8::/32", 3, 0, 34, false},
+		{"::/0", ^uint64(0), 0, 64, false},
+		{"10.0.0.0/24", 0, 0, 0, true},
+		{"10.0.0.0/24", 0, 50, 26, false},
+		{"10.0.0.0/24", 0, 62, 26, false},
+		{"10.0.0.0/24", 0, 63, 25, false},
+		{"10.0.0.0/24", 0, 254, 24, false},
+		{"10.0.0.0/24", 0, 255, 0, true},
+		{"10.0.0.0/24", 0, 2, 31, false},
+		{"10.0.0.0/24", 0, 1, 32, false},
+		{"2001:db8::/32", 0, 256, 120, false},
+		{"2001:db8::/32", 0, 257, 119, false},
+		{"2001:db8::/32", 0, ^uint64(0), 64, false},
+		{"x", 0, 1, 0, true},
+	}
+
+	for _, tt := range tests {
+		var got int
+		var err error
+		if tt.subnets > 0 || tt.hosts == 0 {
+			got, err = SplitPrefixForSubnets(tt.cidr, tt.subnets)
+		} else {
+			got, err = SplitPrefixForHosts(tt.cidr, tt.hosts)
+		}
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("split %q into %d subnets or %d hosts = %d, %v, want %d", tt.cidr, tt.subnets, tt.hosts, got, err, tt.want)
+		}
+	}
+}
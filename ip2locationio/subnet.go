@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// The SubnetInfo struct stores the network details of a CIDR.
+type SubnetInfo struct {
+	CIDR         string      `json:"cidr"`
+	Address      string      `json:"address"`
+	Network      string      `json:"network"`
+	Broadcast    string      `json:"broadcast,omitempty"`
+	Netmask      string      `json:"netmask"`
+	Wildcard     string      `json:"wildcard"`
+	PrefixLength int         `json:"prefix_length"`
+	FirstHost    string      `json:"first_host"`
+	LastHost     string      `json:"last_host"`
+	TotalHosts   json.Number `json:"total_hosts"`
+	UsableHosts  json.Number `json:"usable_hosts"`
+	Class        string      `json:"class,omitempty"`
+	IsPrivate    bool        `json:"is_private"`
+	IsReserved   bool        `json:"is_reserved"`
+}
+
+// Subnet returns the network details for the CIDR, or for the IP address and netmask
+// where the netmask is either a prefix length or written as an IP address.
+func Subnet(address string, netmask string) (SubnetInfo, error) {
+	if i := strings.Index(address, "/"); i != -1 && netmask == "" {
+		address, netmask = address[:i], address[i+1:]
+	} else if netmask == "" {
+		return SubnetInfo{}, errors.New("Not a valid CIDR.")
+	}
+
+	ipv6 := false
+	if IsIPv6(address) {
+		ipv6 = true
+	} else if !IsIPv4(address) {
+		return SubnetInfo{}, errors.New("Not a valid CIDR.")
+	}
+
+	bits, err := maskBits(netmask, ipv6)
+	if err != nil {
+		return SubnetInfo{}, err
+	}
+
+	width := 32
+	if ipv6 {
+		width = 128
+	}
+
+	addr, _ := parseAddr(address)
+	hostMask := lowMask(width - bits)
+	netMask := hostMask.Not().And(lowMask(width))
+	lo := uint128FromAddr(addr).And(netMask)
+	return newSubnetInfo(addr, lo, lo.Or(hostMask), netMask, hostMask, bits, ipv6), nil
+}
+
+// returns the network details from the subnet data.
+func newSubnetInfo(addr netip.Addr, lo uint128, hi uint128, netMask uint128, hostMask uint128, bits int, ipv6 bool) SubnetInfo {
+	one := uint128{0, 1}
+	network := lo.Addr(ipv6)
+	total := hostMask.BigInt()
+	total.Add(total, big.NewInt(1))
+
+	info := SubnetInfo{
+		CIDR:         network.String() + "/" + strconv.Itoa(bits),
+		Address:      addr.String(),
+		Network:      network.String(),
+		Netmask:      netMask.Addr(ipv6).String(),
+		Wildcard:     hostMask.Addr(ipv6).String(),
+		PrefixLength: bits,
+		FirstHost:    network.String(),
+		LastHost:     hi.Addr(ipv6).String(),
+		TotalHosts:   json.Number(total.String()),
+		UsableHosts:  json.Number(total.String()),
+		IsPrivate:    network.IsPrivate() && hi.Addr(ipv6).IsPrivate(),
+		IsReserved:   isSpecialRange(lo, hi, ipv6),
+	}
+
+	if ipv6 {
+		return info
+	}
+
+	info.Broadcast = hi.Addr(false).String()
+	info.Class = ipv4Class(network)
+
+	// the network and broadcast addresses are not usable, except in /31 and /32
+	if bits < 31 {
+		first, _ := lo.Add(one)
+		info.FirstHost = first.Addr(false).String()
+		info.LastHost = hi.Sub(one).Addr(false).String()
+		info.UsableHosts = json.Number(total.Sub(total, big.NewInt(2)).String())
+	}
+
+	return info
+}
+
+// returns the prefix length for the netmask, which is either a prefix length
+// or a contiguous netmask written as an IP address.
+func maskBits(netmask string, ipv6 bool) (int, error) {
+	width := 32
+	if ipv6 {
+		width = 128
+	}
+
+	if bits, err := strconv.Atoi(netmask); err == nil && prefixRegex6.MatchString(netmask) {
+		if bits > width {
+			return 0, errors.New("Not a valid netmask.")
+		}
+		return bits, nil
+	}
+
+	if (ipv6 && !IsIPv6(netmask)) || (!ipv6 && !IsIPv4(netmask)) {
+		return 0, errors.New("Not a valid netmask.")
+	}
+
+	mask, _ := parseAddr(netmask)
+	hostMask := uint128FromAddr(mask).Not().And(lowMask(width))
+
+	// the host mask must be all ones from the lowest bit
+	if next, _ := hostMask.Add(uint128{0, 1}); hostMask.And(next) != (uint128{}) {
+		return 0, errors.New("Not a valid netmask.")
+	}
+
+	return width - (128 - hostMask.LeadingZeros()), nil
+}
+
+// returns the classful network class of the IPv4 address.
+func ipv4Class(addr netip.Addr) string {
+	b := addr.As4()
+	switch {
+	case b[0] < 128:
+		return "A"
+	case b[0] < 192:
+		return "B"
+	case b[0] < 224:
+		return "C"
+	case b[0] < 240:
+		return "D"
+	}
+	return "E"
+}
+
+func PrintSubnet(address string, netmask string) int {
+	info, err := Subnet(address, netmask)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if outputFormat == "json" {
+		byteValue, err := json.Marshal(info)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Printf("%s\n", byteValue)
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "CIDR:\t%s\n", info.CIDR)
+	fmt.Fprintf(w, "Address:\t%s\n", info.Address)
+	fmt.Fprintf(w, "Network:\t%s\n", info.Network)
+	if info.Broadcast != "" {
+		fmt.Fprintf(w, "Broadcast:\t%s\n", info.Broadcast)
+	}
+	fmt.Fprintf(w, "Netmask:\t%s\n", info.Netmask)
+	fmt.Fprintf(w, "Wildcard:\t%s\n", info.Wildcard)
+	fmt.Fprintf(w, "Prefix length:\t%d\n", info.PrefixLength)
+	fmt.Fprintf(w, "First host:\t%s\n", info.FirstHost)
+	fmt.Fprintf(w, "Last host:\t%s\n", info.LastHost)
+	fmt.Fprintf(w, "Total hosts:\t%s\n", info.TotalHosts)
+	fmt.Fprintf(w, "Usable hosts:\t%s\n", info.UsableHosts)
+	if info.Class != "" {
+		fmt.Fprintf(w, "Class:\t%s\n", info.Class)
+	}
+	fmt.Fprintf(w, "Private:\t%t\n", info.IsPrivate)
+	fmt.Fprintf(w, "Reserved:\t%t\n", info.IsReserved)
+	w.Flush()
+	return 0
+}
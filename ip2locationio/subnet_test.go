@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSubnet(t *testing.T) {
+	tests := []struct {
+		address string
+		netmask string
+		want    SubnetInfo
+	}{
+		{"192.168.1.10/24", "", SubnetInfo{CIDR: "192.168.1.0/24", Address: "192.168.1.10", Network: "192.168.1.0", Broadcast: "192.168.1.255", Netmask: "255.255.255.0", Wildcard: "0.0.0.255", PrefixLength: 24, FirstHost: "192.168.1.1", LastHost: "192.168.1.254", TotalHosts: "256", UsableHosts: "254", Class: "C", IsPrivate: true, IsReserved: true}},
+		{"192.168.1.10", "255.255.255.0", SubnetInfo{CIDR: "192.168.1.0/24", Address: "192.168.1.10", Network: "192.168.1.0", Broadcast: "192.168.1.255", Netmask: "255.255.255.0", Wildcard: "0.0.0.255", PrefixLength: 24, FirstHost: "192.168.1.1", LastHost: "192.168.1.254", TotalHosts: "256", UsableHosts: "254", Class: "C", IsPrivate: true, IsReserved: true}},
+		{"8.8.8.8", "30", SubnetInfo{CIDR: "8.8.8.8/30", Address: "8.8.8.8", Network: "8.8.8.8", Broadcast: "8.8.8.11", Netmask: "255.255.255.252", Wildcard: "0.0.0.3", PrefixLength: 30, FirstHost: "8.8.8.9", LastHost: "8.8.8.10", TotalHosts: "4", UsableHosts: "2", Class: "A"}},
+		{"8.8.8.9/31", "", SubnetInfo{CIDR: "8.8.8.8/31", Address: "8.8.8.9", Network: "8.8.8.8", Broadcast: "8.8.8.9", Netmask: "255.255.255.254", Wildcard: "0.0.0.1", PrefixLength: 31, FirstHost: "8.8.8.8", LastHost: "8.8.8.9", TotalHosts: "2", UsableHosts: "2", Class: "A"}},
+		{"224.0.0.1/32", "", SubnetInfo{CIDR: "224.0.0.1/32", Address: "224.0.0.1", Network: "224.0.0.1", Broadcast: "224.0.0.1", Netmask: "255.255.255.255", Wildcard: "0.0.0.0", PrefixLength: 32, FirstHost: "224.0.0.1", LastHost: "224.0.0.1", TotalHosts: "1", UsableHosts: "1", Class: "D", IsReserved: true}},
+		{"2001:db8::1/48", "", SubnetInfo{CIDR: "2001:db8::/48", Address: "2001:db8::1", Network: "2001:db8::", Netmask: "ffff:ffff:ffff::", Wildcard: "::ffff:ffff:ffff:ffff:ffff", PrefixLength: 48, FirstHost: "2001:db8::", LastHost: "2001:db8:0:ffff:ffff:ffff:ffff:ffff", TotalHosts: "1208925819614629174706176", UsableHosts: "1208925819614629174706176", IsReserved: true}},
+		{"fd00::1", "ffff:ffff:ffff:ffff::", SubnetInfo{CIDR: "fd00::/64", Address: "fd00::1", Network: "fd00::", Netmask: "ffff:ffff:ffff:ffff::", Wildcard: "::ffff:ffff:ffff:ffff", PrefixLength: 64, FirstHost: "fd00::", LastHost: "fd00::ffff:ffff:ffff:ffff", TotalHosts: "18446744073709551616", UsableHosts: "18446744073709551616", IsPrivate: true, IsReserved: true}},
+		{"::/0", "", SubnetInfo{CIDR: "::/0", Address: "::", Network: "::", Netmask: "::", Wildcard: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", PrefixLength: 0, FirstHost: "::", LastHost: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", TotalHosts: "340282366920938463463374607431768211456", UsableHosts: "340282366920938463463374607431768211456"}},
+		{"0.0.0.0/0", "", SubnetInfo{CIDR: "0.0.0.0/0", Address: "0.0.0.0", Network: "0.0.0.0", Broadcast: "255.255.255.255", Netmask: "0.0.0.0", Wildcard: "255.255.255.255", PrefixLength: 0, FirstHost: "0.0.0.1", LastHost: "255.255.255.254", TotalHosts: "4294967296", UsableHosts: "4294967294", Class: "A"}},
+		{"10.0.0.0/7", "", SubnetInfo{CIDR: "10.0.0.0/7", Address: "10.0.0.0", Network: "10.0.0.0", Broadcast: "11.255.255.255", Netmask: "254.0.0.0", Wildcard: "1.255.255.255", PrefixLength: 7, FirstHost: "10.0.0.1", LastHost: "11.255.255.254", TotalHosts: "33554432", UsableHosts: "33554430", Class: "A"}},
+	}
+
+	for _, tt := range tests {
+		got, err := Subnet(tt.address, tt.netmask)
+		if err != nil || got != tt.want {
+			t.Errorf("Subnet(%q, %q) = %+v, %v, want %+v", tt.address, tt.netmask, got, err, tt.want)
+		}
+	}
+}
+
+func TestSubnetErrors(t *testing.T) {
+	tests := []struct {
+		address string
+		netmask string
+	}{
+		{"192.168.1.10", ""},
+		{"192.168.1.10/33", ""},
+		{"192.168.1.10", "255.0.255.0"},
+		{"192.168.1.10", "ffff::"},
+		{"2001:db8::", "255.255.255.0"},
+		{"2001:db8::/129", ""},
+		{"x/24", ""},
+		{"192.168.1.10", "-1"},
+	}
+
+	for _, tt := range tests {
+		if _, err := Subnet(tt.address, tt.netmask); err == nil {
+			t.Errorf("Subnet(%q, %q) error = nil, want error", tt.address, tt.netmask)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+// The PrefixTrie struct stores CIDRs in a path-compressed binary trie
+// to find the most specific CIDR containing an IP address or CIDR.
+type PrefixTrie struct {
+	root4 *trieNode
+	root6 *trieNode
+}
+
+// The trieNode struct stores a CIDR in the trie, where nodes without
+// an entry only branch to their children.
+type trieNode struct {
+	prefix prefix
+	entry  string
+	set    bool
+	child  [2]*trieNode
+}
+
+// Insert adds the CIDRs covering the range to the trie, keeping the entry
+// to report when a lookup matches them.
+func (t *PrefixTrie) Insert(r ipRange, entry string) {
+	root, width := t.root(r.IPv6)
+
+	for _, p := range rangeToPrefixes(r.Start, r.End, width) {
+		insertPrefix(root, p, entry, width)
+	}
+}
+
+// Lookup returns the entry of the most specific CIDR containing the whole range,
+// or false if there is none.
+func (t *PrefixTrie) Lookup(r ipRange) (string, bool) {
+	root, width := t.root(r.IPv6)
+
+	// the number of leading bits the start and end addresses of the range share
+	bits := r.Start.Xor(r.End).LeadingZeros() - (128 - width)
+	if bits < 0 {
+		bits = 0
+	}
+
+	var best *trieNode
+	for n := *root; n != nil && n.prefix.Bits <= bits && prefixContains(n.prefix, r.Start, width); {
+		if n.set {
+			best = n
+		}
+		if n.prefix.Bits == width {
+			break
+		}
+		n = n.child[bitAt(r.Start, n.prefix.Bits, width)]
+	}
+
+	if best == nil {
+		return "", false
+	}
+	return best.entry, true
+}
+
+// returns the root for the family and the address width.
+func (t *PrefixTrie) root(ipv6 bool) (**trieNode, int) {
+	if ipv6 {
+		return &t.root6, 128
+	}
+	return &t.root4, 32
+}
+
+// adds the CIDR below the node, splitting the compressed paths where needed.
+func insertPrefix(node **trieNode, p prefix, entry string, width int) {
+	for {
+		n := *node
+		if n == nil {
+			*node = &trieNode{prefix: p, entry: entry, set: true}
+			return
+		}
+
+		common := commonBits(n.prefix, p, width)
+
+		if common == n.prefix.Bits && common == p.Bits {
+			// the first entry for a CIDR is kept
+			if !n.set {
+				n.entry, n.set = entry, true
+			}
+			return
+		} else if common == n.prefix.Bits {
+			node = &n.child[bitAt(p.Start, common, width)]
+			continue
+		}
+
+		leaf := &trieNode{prefix: p, entry: entry, set: true}
+		if common == p.Bits {
+			// the new CIDR contains the node
+			leaf.child[bitAt(n.prefix.Start, common, width)] = n
+			*node = leaf
+			return
+		}
+
+		branch := &trieNode{prefix: prefix{p.Start.And(lowMask(width - common).Not()), common}}
+		branch.child[bitAt(n.prefix.Start, common, width)] = n
+		branch.child[bitAt(p.Start, common, width)] = leaf
+		*node = branch
+		return
+	}
+}
+
+// returns the number of leading bits the CIDRs share, up to the shorter prefix length.
+func commonBits(a prefix, b prefix, width int) int {
+	common := a.Start.Xor(b.Start).LeadingZeros() - (128 - width)
+	if common > a.Bits {
+		common = a.Bits
+	}
+	if common > b.Bits {
+		common = b.Bits
+	}
+	return common
+}
+
+// returns true if the CIDR contains the address.
+func prefixContains(p prefix, addr uint128, width int) bool {
+	return addr.And(lowMask(width-p.Bits).Not()) == p.Start
+}
+
+// returns the bit of the address at the position, counting from 0 for the most significant bit.
+func bitAt(addr uint128, i int, width int) int {
+	pos := width - 1 - i
+	if pos >= 64 {
+		return int(addr.Hi>>(pos-64)) & 1
+	}
+	return int(addr.Lo>>pos) & 1
+}
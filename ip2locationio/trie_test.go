@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestPrefixTrie(t *testing.T) {
+	trie, err := NewPrefixTrie([]string{
+		"10.0.0.0/8", "10.1.0.0/16", "10.1.2.0/24", "10.1.2.0/24 ", "192.168.0.0-192.168.0.255",
+		"172.16.0.1-172.16.0.6", "0.0.0.0/0", "2001:db8::/32", "2001:db8:1::1", "::ffff:1.2.3.4",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+		found bool
+	}{
+		{"10.1.2.3", "10.1.2.0/24", true},
+		{"10.1.3.3", "10.1.0.0/16", true},
+		{"10.2.0.0/16", "10.0.0.0/8", true},
+		{"10.1.2.0/23", "10.1.0.0/16", true},
+		{"10.0.0.0/7", "0.0.0.0/0", true},
+		{"192.168.0.0/24", "192.168.0.0-192.168.0.255", true},
+		{"172.16.0.4/31", "172.16.0.1-172.16.0.6", true},
+		{"172.16.0.0/29", "0.0.0.0/0", true},
+		{"1.2.3.4", "::ffff:1.2.3.4", true},
+		{"2001:db8:1::1", "2001:db8:1::1", true},
+		{"2001:db8:1::/64", "2001:db8::/32", true},
+		{"2001:db9::", "", false},
+		{"::/0", "", false},
+	}
+
+	for _, tt := range tests {
+		got, found, err := Contains(trie, tt.query)
+		if err != nil || got != tt.want || found != tt.found {
+			t.Errorf("Contains(%q) = %q, %v, %v, want %q, %v", tt.query, got, found, err, tt.want, tt.found)
+		}
+	}
+
+	for _, query := range []string{"10.0.0.1-10.0.0.2", "10.0.0.0/33", "x"} {
+		if _, _, err := Contains(trie, query); err == nil {
+			t.Errorf("Contains(%q) error = nil, want error", query)
+		}
+	}
+}
+
+func FuzzPrefixTrie(f *testing.F) {
+	f.Add([]byte{10, 0, 0, 0, 8, 10, 1, 0, 0, 16}, uint32(0x0a010203), uint8(32))
+	f.Add([]byte{0, 0, 0, 0, 0}, uint32(0), uint8(0))
+
+	f.Fuzz(func(t *testing.T, b []byte, addr uint32, bits uint8) {
+		var prefixes []prefix
+		trie := &PrefixTrie{}
+		for i := 0; i+4 < len(b); i = i + 5 {
+			n := int(b[i+4]) % 33
+			start := uint128{0, uint64(uint32(b[i])<<24 | uint32(b[i+1])<<16 | uint32(b[i+2])<<8 | uint32(b[i+3]))}.And(lowMask(32 - n).Not())
+			p := prefix{start, n}
+			prefixes = append(prefixes, p)
+			trie.Insert(ipRange{p.Start, p.Start.Or(lowMask(32 - n)), false}, fmt.Sprint(len(prefixes)-1))
+		}
+
+		n := int(bits) % 33
+		query := prefix{uint128{0, uint64(addr)}.And(lowMask(32 - n).Not()), n}
+
+		// the most specific prefix containing the query, keeping the first of duplicates
+		want := -1
+		for i, p := range prefixes {
+			if p.Bits <= query.Bits && prefixContains(p, query.Start, 32) && (want == -1 || p.Bits > prefixes[want].Bits) {
+				want = i
+			}
+		}
+
+		got, found := trie.Lookup(ipRange{query.Start, query.Start.Or(lowMask(32 - n)), false})
+		if found != (want != -1) || (found && got != fmt.Sprint(want)) {
+			t.Fatalf("Lookup(%v) = %q, %v, want %d", query, got, found, want)
+		}
+	})
+}
+
+func BenchmarkPrefixTrieLookup(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	trie := &PrefixTrie{}
+	for i := 0; i < 100000; i++ {
+		bits := 8 + rnd.Intn(25)
+		start := uint128{0, uint64(rnd.Uint32())}.And(lowMask(32 - bits).Not())
+		trie.Insert(ipRange{start, start.Or(lowMask(32 - bits)), false}, "")
+	}
+
+	queries := make([]ipRange, 1024)
+	for i := range queries {
+		addr := uint128{0, uint64(rnd.Uint32())}
+		queries[i] = ipRange{addr, addr, false}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.Lookup(queries[i%len(queries)])
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"net/netip"
+)
+
+// The uint128 struct stores an IP address as a fixed-width 128-bit number.
+// IPv4 addresses use the lowest 32 bits.
+type uint128 struct {
+	Hi uint64
+	Lo uint64
+}
+
+// returns the number for the IP address, using the lowest 32 bits for IPv4.
+func uint128FromAddr(addr netip.Addr) uint128 {
+	if addr.Is4() {
+		b := addr.As4()
+		return uint128{0, uint64(binary.BigEndian.Uint32(b[:]))}
+	}
+
+	b := addr.As16()
+	return uint128{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
+
+// Addr returns the IPv4 or IPv6 address for the number.
+func (u uint128) Addr(ipv6 bool) netip.Addr {
+	if !ipv6 {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(u.Lo))
+		return netip.AddrFrom4(b)
+	}
+
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.Hi)
+	binary.BigEndian.PutUint64(b[8:], u.Lo)
+	return netip.AddrFrom16(b)
+}
+
+// Cmp returns -1, 0 or 1 if u is less than, equal to or greater than v.
+func (u uint128) Cmp(v uint128) int {
+	if u.Hi < v.Hi || (u.Hi == v.Hi && u.Lo < v.Lo) {
+		return -1
+	} else if u == v {
+		return 0
+	}
+	return 1
+}
+
+// Add returns u + v and whether the result overflowed.
+func (u uint128) Add(v uint128) (uint128, bool) {
+	lo, carry := bits.Add64(u.Lo, v.Lo, 0)
+	hi, carry := bits.Add64(u.Hi, v.Hi, carry)
+	return uint128{hi, lo}, carry != 0
+}
+
+// Sub returns u - v, wrapping around if v is greater than u.
+func (u uint128) Sub(v uint128) uint128 {
+	lo, borrow := bits.Sub64(u.Lo, v.Lo, 0)
+	hi, _ := bits.Sub64(u.Hi, v.Hi, borrow)
+	return uint128{hi, lo}
+}
+
+func (u uint128) And(v uint128) uint128 {
+	return uint128{u.Hi & v.Hi, u.Lo & v.Lo}
+}
+
+func (u uint128) Or(v uint128) uint128 {
+	return uint128{u.Hi | v.Hi, u.Lo | v.Lo}
+}
+
+func (u uint128) Not() uint128 {
+	return uint128{^u.Hi, ^u.Lo}
+}
+
+func (u uint128) Xor(v uint128) uint128 {
+	return uint128{u.Hi ^ v.Hi, u.Lo ^ v.Lo}
+}
+
+// LeadingZeros returns the number of leading zero bits, which is 128 for zero.
+func (u uint128) LeadingZeros() int {
+	if u.Hi != 0 {
+		return bits.LeadingZeros64(u.Hi)
+	}
+	return 64 + bits.LeadingZeros64(u.Lo)
+}
+
+// TrailingZeros returns the number of trailing zero bits, which is 128 for zero.
+func (u uint128) TrailingZeros() int {
+	if u.Lo != 0 {
+		return bits.TrailingZeros64(u.Lo)
+	}
+	return 64 + bits.TrailingZeros64(u.Hi)
+}
+
+// BigInt returns the number as a big.Int.
+func (u uint128) BigInt() *big.Int {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], u.Hi)
+	binary.BigEndian.PutUint64(b[8:], u.Lo)
+	return new(big.Int).SetBytes(b[:])
+}
+
+// returns the number with the lowest n bits set, e.g. the host mask for n host bits.
+func lowMask(n int) uint128 {
+	if n <= 0 {
+		return uint128{}
+	} else if n >= 128 {
+		return uint128{^uint64(0), ^uint64(0)}
+	} else if n > 64 {
+		return uint128{^uint64(0) >> (128 - n), ^uint64(0)}
+	}
+	return uint128{0, ^uint64(0) >> (64 - n)}
+}
+
+// The prefix struct stores a CIDR block as its first address and prefix length.
+type prefix struct {
+	Start uint128
+	Bits  int
+}
+
+// returns the minimal list of CIDR blocks covering the range from start to end,
+// where width is 32 for IPv4 and 128 for IPv6.
+func rangeToPrefixes(start uint128, end uint128, width int) []prefix {
+	var res []prefix
+
+	for start.Cmp(end) <= 0 {
+		// the largest block aligned on the start address which does not go past the end
+		size := start.TrailingZeros()
+		if size > width {
+			size = width
+		}
+		for size > 0 && start.Or(lowMask(size)).Cmp(end) > 0 {
+			size--
+		}
+
+		res = append(res, prefix{start, width - size})
+
+		last := start.Or(lowMask(size))
+		if last == end {
+			break
+		}
+		start, _ = last.Add(uint128{0, 1})
+	}
+
+	return res
+}
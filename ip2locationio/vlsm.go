@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// The VLSMRequirement struct stores the name and number of hosts of a subnet to allocate.
+type VLSMRequirement struct {
+	Name  string
+	Hosts uint64
+}
+
+// The VLSMSubnet struct stores a subnet allocated for a requirement.
+type VLSMSubnet struct {
+	Name        string      `json:"name"`
+	Hosts       uint64      `json:"hosts"`
+	CIDR        string      `json:"cidr"`
+	UsableHosts json.Number `json:"usable_hosts"`
+	FirstHost   string      `json:"first_host"`
+	LastHost    string      `json:"last_host"`
+}
+
+// The VLSMPlan struct stores the subnets allocated in the parent CIDR and the free space left.
+type VLSMPlan struct {
+	Parent  string       `json:"parent"`
+	Subnets []VLSMSubnet `json:"subnets"`
+	Free    []string     `json:"free"`
+}
+
+// ParseVLSMRequirements returns the requirements written as NAME:HOSTS.
+func ParseVLSMRequirements(args []string) ([]VLSMRequirement, error) {
+	var res []VLSMRequirement
+
+	for _, arg := range args {
+		i := strings.LastIndex(arg, ":")
+		if i <= 0 {
+			return nil, errors.New("Not a valid requirement: " + arg + ". Use NAME:HOSTS.")
+		}
+
+		hosts, err := strconv.ParseUint(arg[i+1:], 10, 64)
+		if err != nil || hosts == 0 {
+			return nil, errors.New("Not a valid requirement: " + arg + ". Use NAME:HOSTS.")
+		}
+		res = append(res, VLSMRequirement{arg[:i], hosts})
+	}
+
+	return res, nil
+}
+
+// VLSM allocates the smallest subnet for each requirement in the parent CIDR without overlaps,
+// largest first so that the subnets stay aligned and the free space is kept together.
+func VLSM(parent string, reqs []VLSMRequirement) (VLSMPlan, error) {
+	r, err := ParseIPRange(parent)
+	if err != nil || !strings.Contains(parent, "/") {
+		return VLSMPlan{}, errors.New("Not a valid CIDR.")
+	} else if len(reqs) == 0 {
+		return VLSMPlan{}, errors.New("No requirement supplied.")
+	}
+
+	width := 32
+	if r.IPv6 {
+		width = 128
+	}
+
+	hostBits := make([]int, len(reqs))
+	for i, req := range reqs {
+		if hostBits[i], err = hostBitsFor(req.Hosts, r.IPv6); err != nil {
+			return VLSMPlan{}, err
+		}
+	}
+
+	order := make([]int, len(reqs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return hostBits[order[i]] > hostBits[order[j]]
+	})
+
+	plan := VLSMPlan{Parent: r.CIDRs()[0], Subnets: []VLSMSubnet{}, Free: []string{}}
+	next := r.Start
+	full := false
+
+	for _, i := range order {
+		last := next.Or(lowMask(hostBits[i]))
+		if full || last.Cmp(r.End) > 0 {
+			return VLSMPlan{}, errors.New("Not enough space in " + plan.Parent + " for " + reqs[i].Name + " with " + strconv.FormatUint(reqs[i].Hosts, 10) + " hosts.")
+		}
+
+		cidr := next.Addr(r.IPv6).String() + "/" + strconv.Itoa(width-hostBits[i])
+		info, err := Subnet(cidr, "")
+		if err != nil {
+			return VLSMPlan{}, err
+		}
+		plan.Subnets = append(plan.Subnets, VLSMSubnet{reqs[i].Name, reqs[i].Hosts, info.CIDR, info.UsableHosts, info.FirstHost, info.LastHost})
+
+		var overflow bool
+		next, overflow = last.Add(uint128{0, 1})
+		full = overflow || last == r.End
+	}
+
+	if !full {
+		plan.Free = ipRange{next, r.End, r.IPv6}.CIDRs()
+	}
+
+	return plan, nil
+}
+
+func PrintVLSM(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("Not a valid CIDR.")
+		return 1
+	}
+
+	reqs, err := ParseVLSMRequirements(args[1:])
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	plan, err := VLSM(arg(args, 0), reqs)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if outputFormat == "json" {
+		byteValue, err := json.Marshal(plan)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		fmt.Printf("%s\n", byteValue)
+		return 0
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tHOSTS\tCIDR\tUSABLE HOSTS\tFIRST HOST\tLAST HOST")
+	for _, s := range plan.Subnets {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\n", s.Name, s.Hosts, s.CIDR, s.UsableHosts, s.FirstHost, s.LastHost)
+	}
+	for _, cidr := range plan.Free {
+		fmt.Fprintf(w, "(free)\t\t%s\n", cidr)
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVLSM(t *testing.T) {
+	reqs, err := ParseVLSMRequirements([]string{"wan:2", "sales:50", "it:20", "lab:20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := VLSM("10.0.0.0/24", reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := VLSMPlan{
+		Parent: "10.0.0.0/24",
+		Subnets: []VLSMSubnet{
+			{"sales", 50, "10.0.0.0/26", "62", "10.0.0.1", "10.0.0.62"},
+			{"it", 20, "10.0.0.64/27", "30", "10.0.0.65", "10.0.0.94"},
+			{"lab", 20, "10.0.0.96/27", "30", "10.0.0.97", "10.0.0.126"},
+			{"wan", 2, "10.0.0.128/31", "2", "10.0.0.128", "10.0.0.129"},
+		},
+		Free: []string{"10.0.0.130/31", "10.0.0.132/30", "10.0.0.136/29", "10.0.0.144/28", "10.0.0.160/27", "10.0.0.192/26"},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("VLSM() = %+v, want %+v", plan, want)
+	}
+}
+
+func TestVLSMErrors(t *testing.T) {
+	tests := []struct {
+		parent string
+		reqs   []string
+	}{
+		{"10.0.0.0/24", []string{"a:200", "b:100"}},
+		{"10.0.0.0/24", []string{"a:255"}},
+		{"10.0.0.0/24", nil},
+		{"10.0.0.1", []string{"a:1"}},
+		{"x", []string{"a:1"}},
+	}
+
+	for _, tt := range tests {
+		reqs, err := ParseVLSMRequirements(tt.reqs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := VLSM(tt.parent, reqs); err == nil {
+			t.Errorf("VLSM(%q, %v) error = nil, want error", tt.parent, tt.reqs)
+		}
+	}
+
+	for _, req := range []string{"a", ":5", "a:0", "a:-1", "a:x"} {
+		if _, err := ParseVLSMRequirements([]string{req}); err == nil {
+			t.Errorf("ParseVLSMRequirements(%q) error = nil, want error", req)
+		}
+	}
+}
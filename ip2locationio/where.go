@@ -0,0 +1,376 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The Expr interface is implemented by the nodes of a parsed where expression.
+type Expr interface {
+	Eval(ipl map[string]interface{}) interface{}
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+type fieldExpr struct {
+	path string
+}
+
+type notExpr struct {
+	operand Expr
+}
+
+type logicalExpr struct {
+	op    string
+	left  Expr
+	right Expr
+}
+
+type compareExpr struct {
+	op    string
+	left  Expr
+	right Expr
+}
+
+func (e literalExpr) Eval(ipl map[string]interface{}) interface{} {
+	return e.value
+}
+
+func (e fieldExpr) Eval(ipl map[string]interface{}) interface{} {
+	v, _ := ResolveField(ipl, e.path)
+	return v
+}
+
+func (e notExpr) Eval(ipl map[string]interface{}) interface{} {
+	return !truthy(e.operand.Eval(ipl))
+}
+
+func (e logicalExpr) Eval(ipl map[string]interface{}) interface{} {
+	left := truthy(e.left.Eval(ipl))
+
+	if e.op == "&&" {
+		return left && truthy(e.right.Eval(ipl))
+	}
+	return left || truthy(e.right.Eval(ipl))
+}
+
+func (e compareExpr) Eval(ipl map[string]interface{}) interface{} {
+	left := e.left.Eval(ipl)
+	right := e.right.Eval(ipl)
+
+	switch e.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	}
+
+	cmp, ok := compare(left, right)
+	if !ok {
+		return false
+	}
+
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// returns whether the value counts as true in a where expression.
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case string:
+		return t != ""
+	}
+	return true
+}
+
+// returns whether both values have the same type and value.
+func equal(a interface{}, b interface{}) bool {
+	switch t := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		v, ok := b.(bool)
+		return ok && t == v
+	case float64:
+		v, ok := b.(float64)
+		return ok && t == v
+	case string:
+		v, ok := b.(string)
+		return ok && t == v
+	}
+	return false
+}
+
+// compares numbers or strings, returning false if they cannot be ordered.
+func compare(a interface{}, b interface{}) (int, bool) {
+	switch t := a.(type) {
+	case float64:
+		if v, ok := b.(float64); ok {
+			if t < v {
+				return -1, true
+			} else if t > v {
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if v, ok := b.(string); ok {
+			return strings.Compare(t, v), true
+		}
+	}
+	return 0, false
+}
+
+type token struct {
+	kind  string // "ident", "string", "number", "op", "eof"
+	value string
+}
+
+// splits the where expression into tokens.
+func tokenize(str string) ([]token, error) {
+	var tokens []token
+	runes := []rune(str)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, errors.New("Invalid where expression: unterminated string.")
+			}
+			tokens = append(tokens, token{"string", sb.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i + 1
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{"number", string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_' || runes[j] == '.' || runes[j] == '[' || runes[j] == ']' || runes[j] == '-') {
+				j++
+			}
+			tokens = append(tokens, token{"ident", string(runes[i:j])})
+			i = j
+		default:
+			op := ""
+			if i+1 < len(runes) {
+				switch string(runes[i : i+2]) {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = string(runes[i : i+2])
+				}
+			}
+			if op == "" {
+				switch r {
+				case '<', '>', '!', '(', ')':
+					op = string(r)
+				default:
+					return nil, errors.New("Invalid where expression: unexpected character '" + string(r) + "'.")
+				}
+			}
+			tokens = append(tokens, token{"op", op})
+			i += len(op)
+		}
+	}
+
+	tokens = append(tokens, token{"eof", ""})
+
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != "eof" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == "op" && p.peek().value == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{"||", left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == "op" && p.peek().value == "&&" {
+		p.next()
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr{"&&", left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseCompare() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind == "op" {
+		switch t.value {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return compareExpr{t.value, left, right}, nil
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	t := p.next()
+
+	switch t.kind {
+	case "op":
+		if t.value == "!" {
+			operand, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return notExpr{operand}, nil
+		} else if t.value == "(" {
+			e, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if p.next().value != ")" {
+				return nil, errors.New("Invalid where expression: missing ')'.")
+			}
+			return e, nil
+		}
+	case "string":
+		return literalExpr{t.value}, nil
+	case "number":
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, errors.New("Invalid where expression: bad number '" + t.value + "'.")
+		}
+		return literalExpr{f}, nil
+	case "ident":
+		switch t.value {
+		case "true":
+			return literalExpr{true}, nil
+		case "false":
+			return literalExpr{false}, nil
+		case "null":
+			return literalExpr{nil}, nil
+		}
+		return fieldExpr{t.value}, nil
+	case "eof":
+		return nil, errors.New("Invalid where expression: unexpected end of expression.")
+	}
+
+	return nil, errors.New("Invalid where expression: unexpected '" + t.value + "'.")
+}
+
+// ParseWhere parses a where expression such as
+// `country_code == "US" && proxy.is_vpn`.
+func ParseWhere(str string) (Expr, error) {
+	tokens, err := tokenize(str)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != "eof" {
+		return nil, errors.New("Invalid where expression: unexpected '" + p.peek().value + "'.")
+	}
+
+	return e, nil
+}
+
+// Matches returns true if the lookup result satisfies the where expression.
+func Matches(e Expr, ipl map[string]interface{}) bool {
+	if e == nil {
+		return true
+	}
+	return truthy(e.Eval(ipl))
+}
+
+// returns the field paths used in the expression.
+func exprFields(e Expr) []string {
+	switch t := e.(type) {
+	case fieldExpr:
+		return []string{t.path}
+	case notExpr:
+		return exprFields(t.operand)
+	case logicalExpr:
+		return append(exprFields(t.left), exprFields(t.right)...)
+	case compareExpr:
+		return append(exprFields(t.left), exprFields(t.right)...)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestWhere(t *testing.T) {
+	ipl := loadResult(t, "1.1.1.1")
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`country_code == "AU"`, true},
+		{`country_code == 'US'`, false},
+		{`country_code != "US"`, true},
+		{`proxy.is_vpn`, true},
+		{`!proxy.is_tor`, true},
+		{`fraud_score > 70 && proxy.proxy_type == "VPN"`, true},
+		{`fraud_score >= 85 && fraud_score <= 85`, true},
+		{`fraud_score < 10 || country_code == "US"`, false},
+		{`(fraud_score < 10 || is_proxy) && continent.code == "OC"`, true},
+		{`continent.hemisphere[0] == "south"`, true},
+		{`continent.hemisphere[-1] == "east"`, true},
+		{`city.translation.lang == null`, true},
+		{`missing.field`, false},
+		{`latitude < -27`, true},
+		{`country_code > 5`, false},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseWhere(tt.expr)
+		if err != nil {
+			t.Errorf("ParseWhere(%q) error: %v", tt.expr, err)
+			continue
+		}
+		if got := Matches(e, ipl); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestWhereErrors(t *testing.T) {
+	for _, expr := range []string{`(a == 1`, `"abc`, `a ==`, `a = 1`, `a == 1 b`, `&& a`} {
+		if _, err := ParseWhere(expr); err == nil {
+			t.Errorf("ParseWhere(%q) should fail", expr)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// the IANA RDAP bootstrap files for the IP address and ASN allocations to the registries
+//
+//go:embed rdap/*.json
+var rdapBootstrapFiles embed.FS
+
+// RDAP base URL to query instead of the bootstrap registries, overridden in tests and by --rdap-url
+var rdapBaseURL string
+
+var rdapClient = &http.Client{Timeout: 15 * time.Second}
+
+// The rdapBootstrap struct stores an IANA RDAP bootstrap file.
+type rdapBootstrap struct {
+	Services [][][]string `json:"services"`
+}
+
+// The rdapASNRange struct stores a range of ASNs allocated to a registry.
+type rdapASNRange struct {
+	Start uint32
+	End   uint32
+	URL   string
+}
+
+// The rdapEntity struct stores an entity of an RDAP response.
+type rdapEntity struct {
+	Handle     string        `json:"handle"`
+	Roles      []string      `json:"roles"`
+	VCardArray []interface{} `json:"vcardArray"`
+	Entities   []rdapEntity  `json:"entities"`
+}
+
+// The rdapObject struct stores the fields of an RDAP IP network or autnum response.
+type rdapObject struct {
+	Handle       string       `json:"handle"`
+	Name         string       `json:"name"`
+	Type         string       `json:"type"`
+	Country      string       `json:"country"`
+	StartAddress string       `json:"startAddress"`
+	EndAddress   string       `json:"endAddress"`
+	StartAutnum  uint32       `json:"startAutnum"`
+	EndAutnum    uint32       `json:"endAutnum"`
+	Entities     []rdapEntity `json:"entities"`
+	ErrorCode    int          `json:"errorCode"`
+	Title        string       `json:"title"`
+}
+
+// The WhoisResult struct stores the registration data of an IP network or ASN.
+type WhoisResult struct {
+	Query      string   `json:"query"`
+	Handle     string   `json:"handle"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type,omitempty"`
+	Country    string   `json:"country,omitempty"`
+	Range      string   `json:"range"`
+	CIDRs      []string `json:"cidrs,omitempty"`
+	Registrant string   `json:"registrant,omitempty"`
+	AbuseEmail string   `json:"abuse_email,omitempty"`
+	Source     string   `json:"source"`
+}
+
+// returns the RDAP base URL of the registry for the IP address or ASN.
+func rdapServer(addr netip.Addr, asn uint32, isASN bool) (string, error) {
+	if rdapBaseURL != "" {
+		return strings.TrimSuffix(rdapBaseURL, "/") + "/", nil
+	}
+
+	file := "rdap/ipv4.json"
+	if isASN {
+		file = "rdap/asn.json"
+	} else if addr.Is6() {
+		file = "rdap/ipv6.json"
+	}
+
+	byteValue, err := rdapBootstrapFiles.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var b rdapBootstrap
+	if err := json.Unmarshal(byteValue, &b); err != nil {
+		return "", err
+	}
+
+	if isASN {
+		for _, r := range parseASNRanges(b) {
+			if asn >= r.Start && asn <= r.End {
+				return r.URL, nil
+			}
+		}
+		return "", errors.New("No RDAP server found for AS" + strconv.FormatUint(uint64(asn), 10) + ".")
+	}
+
+	t := &PrefixTrie{}
+	for _, service := range b.Services {
+		if len(service) != 2 || len(service[1]) == 0 {
+			continue
+		}
+		for _, cidr := range service[0] {
+			if r, err := ParseIPRange(cidr); err == nil {
+				t.Insert(r, rdapServiceURL(service[1]))
+			}
+		}
+	}
+
+	u := uint128FromAddr(addr)
+	if url, ok := t.Lookup(ipRange{u, u, addr.Is6()}); ok {
+		return url, nil
+	}
+	return "", errors.New("No RDAP server found for " + addr.String() + ".")
+}
+
+// returns the ASN ranges of the bootstrap file, which are written as START-END or a single ASN.
+func parseASNRanges(b rdapBootstrap) []rdapASNRange {
+	var res []rdapASNRange
+
+	for _, service := range b.Services {
+		if len(service) != 2 || len(service[1]) == 0 {
+			continue
+		}
+		for _, entry := range service[0] {
+			from, to := entry, entry
+			if i := strings.Index(entry, "-"); i != -1 {
+				from, to = entry[:i], entry[i+1:]
+			}
+			start, err1 := strconv.ParseUint(from, 10, 32)
+			end, err2 := strconv.ParseUint(to, 10, 32)
+			if err1 == nil && err2 == nil {
+				res = append(res, rdapASNRange{uint32(start), uint32(end), rdapServiceURL(service[1])})
+			}
+		}
+	}
+
+	return res
+}
+
+// returns the HTTPS URL of the service, or the first one if there is none.
+func rdapServiceURL(urls []string) string {
+	for _, u := range urls {
+		if strings.HasPrefix(u, "https://") {
+			return u
+		}
+	}
+	return urls[0]
+}
+
+// parses the IP address or the ASN, written with or without the AS prefix.
+func parseWhoisQuery(query string) (netip.Addr, uint32, bool, error) {
+	if addr, err := netip.ParseAddr(query); err == nil && addr.Zone() == "" {
+		return addr.Unmap(), 0, false, nil
+	}
+
+	asn, err := ParseASN(query)
+	if err != nil {
+		return netip.Addr{}, 0, false, errors.New("Not a valid IP address or ASN: " + query)
+	}
+	return netip.Addr{}, asn, true, nil
+}
+
+// Whois returns the registration data of the IP address or ASN from the RDAP server of its registry.
+func Whois(query string) (WhoisResult, error) {
+	addr, asn, isASN, err := parseWhoisQuery(query)
+	if err != nil {
+		return WhoisResult{}, err
+	}
+
+	base, err := rdapServer(addr, asn, isASN)
+	if err != nil {
+		return WhoisResult{}, err
+	}
+
+	source := base + "ip/" + addr.String()
+	if isASN {
+		source = base + "autnum/" + strconv.FormatUint(uint64(asn), 10)
+	}
+
+	obj, err := rdapQuery(source)
+	if err != nil {
+		return WhoisResult{}, err
+	}
+
+	res := WhoisResult{
+		Query:   query,
+		Handle:  obj.Handle,
+		Name:    obj.Name,
+		Type:    obj.Type,
+		Country: obj.Country,
+		Source:  source,
+	}
+
+	if isASN {
+		res.Range = "AS" + strconv.FormatUint(uint64(obj.StartAutnum), 10)
+		if obj.EndAutnum != obj.StartAutnum {
+			res.Range += "-AS" + strconv.FormatUint(uint64(obj.EndAutnum), 10)
+		}
+	} else if r, err := ParseRangeEnds(obj.StartAddress, obj.EndAddress); err == nil {
+		res.Range = obj.StartAddress + "-" + obj.EndAddress
+		res.CIDRs = r.CIDRs()
+	}
+
+	if e, ok := findRDAPEntity(obj.Entities, "registrant"); ok {
+		res.Registrant = vCardValue(e.VCardArray, "fn")
+	}
+	if e, ok := findRDAPEntity(obj.Entities, "abuse"); ok {
+		res.AbuseEmail = vCardValue(e.VCardArray, "email")
+	}
+
+	return res, nil
+}
+
+// returns the RDAP object at the URL.
+func rdapQuery(url string) (rdapObject, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return rdapObject{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := rdapClient.Do(req)
+	if err != nil {
+		return rdapObject{}, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return rdapObject{}, err
+	}
+
+	var obj rdapObject
+	jsonErr := json.Unmarshal(bodyBytes, &obj)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return rdapObject{}, errors.New("No RDAP record found at " + url + ".")
+	} else if resp.StatusCode != http.StatusOK {
+		if jsonErr == nil && obj.Title != "" {
+			return rdapObject{}, errors.New("Error: " + obj.Title)
+		}
+		return rdapObject{}, errors.New("Error HTTP " + strconv.Itoa(resp.StatusCode))
+	} else if jsonErr != nil {
+		return rdapObject{}, jsonErr
+	}
+
+	return obj, nil
+}
+
+// returns the first entity with the role, searching the nested entities too.
+func findRDAPEntity(entities []rdapEntity, role string) (rdapEntity, bool) {
+	for _, e := range entities {
+		for _, r := range e.Roles {
+			if r == role {
+				return e, true
+			}
+		}
+	}
+
+	for _, e := range entities {
+		if found, ok := findRDAPEntity(e.Entities, role); ok {
+			return found, true
+		}
+	}
+
+	return rdapEntity{}, false
+}
+
+// returns the text value of the first property with the name in the jCard, e.g. "fn" or "email".
+func vCardValue(vcard []interface{}, name string) string {
+	if len(vcard) < 2 {
+		return ""
+	}
+
+	properties, _ := vcard[1].([]interface{})
+	for _, p := range properties {
+		property, _ := p.([]interface{})
+		if len(property) < 4 || property[0] != name {
+			continue
+		}
+		if value, ok := property[3].(string); ok {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func PrintWhois(args []string) int {
+	if len(args) == 0 {
+		fmt.Println("No IP address or ASN supplied.")
+		return 1
+	}
+
+	for i, query := range args {
+		res, err := Whois(query)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		if outputFormat == "json" {
+			byteValue, err := json.Marshal(res)
+			if err != nil {
+				fmt.Println(err)
+				return 1
+			}
+			fmt.Printf("%s\n", byteValue)
+			continue
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Query:\t%s\n", res.Query)
+		fmt.Fprintf(w, "Handle:\t%s\n", res.Handle)
+		fmt.Fprintf(w, "Name:\t%s\n", res.Name)
+		if res.Type != "" {
+			fmt.Fprintf(w, "Type:\t%s\n", res.Type)
+		}
+		if res.Country != "" {
+			fmt.Fprintf(w, "Country:\t%s\n", res.Country)
+		}
+		fmt.Fprintf(w, "Range:\t%s\n", res.Range)
+		if len(res.CIDRs) > 0 {
+			fmt.Fprintf(w, "CIDRs:\t%s\n", strings.Join(res.CIDRs, ", "))
+		}
+		if res.Registrant != "" {
+			fmt.Fprintf(w, "Registrant:\t%s\n", res.Registrant)
+		}
+		if res.AbuseEmail != "" {
+			fmt.Fprintf(w, "Abuse email:\t%s\n", res.AbuseEmail)
+		}
+		fmt.Fprintf(w, "Source:\t%s\n", res.Source)
+		w.Flush()
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestRDAPServer(t *testing.T) {
+	base := rdapBaseURL
+	rdapBaseURL = ""
+	defer func() { rdapBaseURL = base }()
+
+	tests := map[string]string{
+		"8.8.8.8":         "https://rdap.arin.net/registry/",
+		"1.1.1.1":         "https://rdap.apnic.net/",
+		"193.0.6.139":     "https://rdap.db.ripe.net/",
+		"200.160.2.3":     "https://rdap.lacnic.net/rdap/",
+		"196.216.2.1":     "https://rdap.afrinic.net/rdap/",
+		"2001:4860::8888": "https://rdap.arin.net/registry/",
+		"2a00:1450::1":    "https://rdap.db.ripe.net/",
+		"2001:dc0::1":     "https://rdap.apnic.net/",
+		"AS15169":         "https://rdap.arin.net/registry/",
+		"as3333":          "https://rdap.db.ripe.net/",
+		"4608":            "https://rdap.apnic.net/",
+		"28000":           "https://rdap.lacnic.net/rdap/",
+		"327700":          "https://rdap.afrinic.net/rdap/",
+	}
+
+	for query, want := range tests {
+		addr, asn, isASN, err := parseWhoisQuery(query)
+		if err != nil {
+			t.Fatalf("parseWhoisQuery(%q) error = %v", query, err)
+		}
+		if got, err := rdapServer(addr, asn, isASN); err != nil || got != want {
+			t.Errorf("rdapServer(%q) = %q, %v, want %q", query, got, err, want)
+		}
+	}
+
+	for _, query := range []string{"10.0.0.1", "fe80::1", "AS64512"} {
+		addr, asn, isASN, _ := parseWhoisQuery(query)
+		if got, err := rdapServer(addr, asn, isASN); err == nil {
+			t.Errorf("rdapServer(%q) = %q, want error", query, got)
+		}
+	}
+
+	rdapBaseURL = "http://localhost:8080/rdap"
+	if got, _ := rdapServer(netip.MustParseAddr("10.0.0.1"), 0, false); got != "http://localhost:8080/rdap/" {
+		t.Errorf("rdapServer with the base URL = %q", got)
+	}
+}
+
+func TestVCardValue(t *testing.T) {
+	vcard := []interface{}{"vcard", []interface{}{
+		[]interface{}{"version", map[string]interface{}{}, "text", "4.0"},
+		[]interface{}{"fn", map[string]interface{}{}, "text", "Example Org"},
+		[]interface{}{"email", map[string]interface{}{}, "text", "abuse@example.org"},
+	}}
+
+	if got := vCardValue(vcard, "fn"); got != "Example Org" {
+		t.Errorf("vCardValue(fn) = %q", got)
+	}
+	if got := vCardValue(vcard, "email"); got != "abuse@example.org" {
+		t.Errorf("vCardValue(email) = %q", got)
+	}
+	if got := vCardValue(vcard, "tel"); got != "" {
+		t.Errorf("vCardValue(tel) = %q", got)
+	}
+	if got := vCardValue(nil, "fn"); got != "" {
+		t.Errorf("vCardValue(nil) = %q", got)
+	}
+}